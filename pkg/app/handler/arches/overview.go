@@ -0,0 +1,105 @@
+// SPDX-License-Identifier: GPL-2.0-only
+package arches
+
+import (
+	"slices"
+
+	"soko/pkg/database"
+	"soko/pkg/models"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// categoryCoverage is the number of packages of a category, that
+// are keyworded on an arch. Missing is the number of packages that
+// are keyworded on amd64, but not on the arch.
+type categoryCoverage struct {
+	Arch      string
+	Category  string
+	Packages  int
+	Stable    int
+	Keyworded int
+	Missing   int
+}
+
+// Percent returns the percentage of packages keyworded on the arch
+func (c *categoryCoverage) Percent() int {
+	if c.Packages == 0 {
+		return 0
+	}
+	return c.Keyworded * 100 / c.Packages
+}
+
+// archSummary is the coverage of an arch for the whole tree
+type archSummary struct {
+	Arch *models.Arch
+	categoryCoverage
+}
+
+// Testing returns the number of packages that are only testing on the arch
+func (s *archSummary) Testing() int {
+	return s.Keyworded - s.Stable
+}
+
+// archesOverview compares the arches, for the whole tree as well as per category
+type archesOverview struct {
+	Arches     []*archSummary
+	Categories []string
+	// Coverage contains the coverage by category and arch
+	Coverage map[string]map[string]*categoryCoverage
+}
+
+// getCategoryCoverage returns the coverage of each category on the given arches
+func getCategoryCoverage(arches []string) ([]*categoryCoverage, error) {
+	var coverage []*categoryCoverage
+	_, err := database.DBCon.Query(&coverage, `WITH package_arch AS (
+			SELECT atom, TRIM(LEADING '~' FROM keyword) AS arch, BOOL_OR(keyword NOT LIKE '~%') AS stable
+			FROM versions
+			CROSS JOIN UNNEST(STRING_TO_ARRAY(keywords, ' ')) AS keyword
+			WHERE keyword <> '' AND keyword NOT LIKE '-%'
+			GROUP BY atom, TRIM(LEADING '~' FROM keyword)
+		)
+		SELECT a.arch, p.category,
+			COUNT(*) AS packages,
+			COUNT(*) FILTER (WHERE pa.stable) AS stable,
+			COUNT(pa.atom) AS keyworded,
+			COUNT(*) FILTER (WHERE amd64.atom IS NOT NULL AND pa.atom IS NULL) AS missing
+		FROM UNNEST(?0::TEXT[]) AS a(arch)
+		CROSS JOIN packages AS p
+		LEFT JOIN package_arch AS pa ON pa.atom = p.atom AND pa.arch = a.arch
+		LEFT JOIN package_arch AS amd64 ON amd64.atom = p.atom AND amd64.arch = 'amd64'
+		GROUP BY a.arch, p.category`, pg.Array(arches))
+	return coverage, err
+}
+
+// summarizeCoverage sums up the coverage of the categories per arch
+func summarizeCoverage(arches []*models.Arch, coverage []*categoryCoverage) *archesOverview {
+	overview := &archesOverview{
+		Coverage: make(map[string]map[string]*categoryCoverage),
+	}
+	summaries := make(map[string]*archSummary, len(arches))
+	for _, arch := range arches {
+		summary := &archSummary{Arch: arch, categoryCoverage: categoryCoverage{Arch: arch.Name}}
+		summaries[arch.Name] = summary
+		overview.Arches = append(overview.Arches, summary)
+	}
+
+	for _, c := range coverage {
+		summary, found := summaries[c.Arch]
+		if !found {
+			continue
+		}
+		summary.Packages += c.Packages
+		summary.Stable += c.Stable
+		summary.Keyworded += c.Keyworded
+		summary.Missing += c.Missing
+
+		if _, found := overview.Coverage[c.Category]; !found {
+			overview.Coverage[c.Category] = make(map[string]*categoryCoverage)
+			overview.Categories = append(overview.Categories, c.Category)
+		}
+		overview.Coverage[c.Category][c.Arch] = c
+	}
+	slices.Sort(overview.Categories)
+	return overview
+}
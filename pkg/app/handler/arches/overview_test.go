@@ -0,0 +1,39 @@
+// SPDX-License-Identifier: GPL-2.0-only
+package arches
+
+import (
+	"slices"
+	"testing"
+
+	"soko/pkg/models"
+)
+
+func TestSummarizeCoverage(t *testing.T) {
+	arches := []*models.Arch{{Name: "amd64", Status: models.ArchStable}, {Name: "m68k", Status: models.ArchTesting}}
+	coverage := []*categoryCoverage{
+		{Arch: "amd64", Category: "sys-apps", Packages: 10, Stable: 8, Keyworded: 9},
+		{Arch: "m68k", Category: "sys-apps", Packages: 10, Stable: 1, Keyworded: 4, Missing: 5},
+		{Arch: "amd64", Category: "app-misc", Packages: 5, Stable: 2, Keyworded: 5},
+		{Arch: "m68k", Category: "app-misc", Packages: 5, Keyworded: 1, Missing: 4},
+		{Arch: "s390", Category: "app-misc", Packages: 5},
+	}
+
+	overview := summarizeCoverage(arches, coverage)
+
+	if !slices.Equal(overview.Categories, []string{"app-misc", "sys-apps"}) {
+		t.Errorf("Unexpected categories %q", overview.Categories)
+	}
+	if len(overview.Arches) != 2 {
+		t.Fatalf("Expected 2 arches, got %d", len(overview.Arches))
+	}
+	m68k := overview.Arches[1]
+	if m68k.Arch.Name != "m68k" || m68k.Packages != 15 || m68k.Stable != 1 || m68k.Testing() != 4 || m68k.Missing != 9 || m68k.Percent() != 33 {
+		t.Errorf("Unexpected summary %+v", m68k)
+	}
+	if got := overview.Coverage["app-misc"]["amd64"].Percent(); got != 100 {
+		t.Errorf("Expected 100%% coverage, got %d", got)
+	}
+	if _, found := overview.Coverage["app-misc"]["s390"]; found {
+		t.Errorf("Expected unknown arches to be ignored")
+	}
+}
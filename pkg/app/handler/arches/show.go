@@ -2,24 +2,31 @@
 package arches
 
 import (
+	"encoding/json"
 	"net/http"
 	"soko/pkg/app/handler/feeds"
+	"soko/pkg/app/utils"
 	"strings"
 )
 
 func ShowStable(w http.ResponseWriter, r *http.Request) {
 	arch := r.PathValue("arch")
-	stabilizedVersions, err := getStabilizedVersionsForArch(arch, 50)
+	at, err := utils.ParseAt(r)
+	if err != nil {
+		utils.AtError(w, err)
+		return
+	}
+	stabilizedVersions, err := getStabilizedVersionsForArch(arch, 50, at)
 	if err != nil {
 		http.NotFound(w, r)
 		return
 	}
-	renderPage(w, r, arch, changedVersions(arch, "stable", stabilizedVersions))
+	renderPage(w, r, arch, changedVersions(arch, "stable", stabilizedVersions, at))
 }
 
 func ShowStableFeed(w http.ResponseWriter, r *http.Request) {
 	arch := r.PathValue("arch")
-	stabilizedVersions, err := getStabilizedVersionsForArch(arch, 250)
+	stabilizedVersions, err := getStabilizedVersionsForArch(arch, 250, nil)
 	if err != nil {
 		http.NotFound(w, r)
 		return
@@ -31,17 +38,22 @@ func ShowStableFeed(w http.ResponseWriter, r *http.Request) {
 
 func ShowKeyworded(w http.ResponseWriter, r *http.Request) {
 	arch := r.PathValue("arch")
-	keywordedVersions, err := getKeywordedVersionsForArch(arch, 50)
+	at, err := utils.ParseAt(r)
+	if err != nil {
+		utils.AtError(w, err)
+		return
+	}
+	keywordedVersions, err := getKeywordedVersionsForArch(arch, 50, at)
 	if err != nil {
 		http.NotFound(w, r)
 		return
 	}
-	renderPage(w, r, arch, changedVersions(arch, "keyworded", keywordedVersions))
+	renderPage(w, r, arch, changedVersions(arch, "keyworded", keywordedVersions, at))
 }
 
 func ShowKeywordedFeed(w http.ResponseWriter, r *http.Request) {
 	arch := r.PathValue("arch")
-	keywordedVersions, err := getKeywordedVersionsForArch(arch, 250)
+	keywordedVersions, err := getKeywordedVersionsForArch(arch, 250, nil)
 	if err != nil {
 		http.NotFound(w, r)
 		return
@@ -51,12 +63,102 @@ func ShowKeywordedFeed(w http.ResponseWriter, r *http.Request) {
 	feeds.Changes(feedTitle, feedDescription, keywordedVersions, w)
 }
 
-func ShowLeafPackages(w http.ResponseWriter, r *http.Request) {
+func ShowDropped(w http.ResponseWriter, r *http.Request) {
 	arch := r.PathValue("arch")
-	leafs, err := getLeafPackagesForArch(arch)
+	at, err := utils.ParseAt(r)
+	if err != nil {
+		utils.AtError(w, err)
+		return
+	}
+	droppedVersions, err := getDroppedVersionsForArch(arch, 50, at)
 	if err != nil {
 		http.NotFound(w, r)
 		return
 	}
-	w.Write([]byte(strings.Join(leafs, "\n")))
+	renderPage(w, r, arch, changedVersions(arch, "dropped", droppedVersions, at))
+}
+
+func ShowDroppedFeed(w http.ResponseWriter, r *http.Request) {
+	arch := r.PathValue("arch")
+	droppedVersions, err := getDroppedVersionsForArch(arch, 250, nil)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	feedTitle := "Dropped and destabilized packages in Gentoo on " + arch
+	feedDescription := feedTitle
+	feeds.Changes(feedTitle, feedDescription, droppedVersions, w)
+}
+
+// ShowLeafPackagesList lists the atoms of the leaf packages as plain
+// text, which is relied upon by scripts of the arch teams
+func ShowLeafPackagesList(w http.ResponseWriter, r *http.Request) {
+	leafs, err := utils.GetLeafPackages(r.PathValue("arch"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	atoms := make([]string, len(leafs))
+	for i, leaf := range leafs {
+		atoms[i] = leaf.Atom
+	}
+	w.Write([]byte(strings.Join(atoms, "\n")))
+}
+
+func ShowLeafPackages(w http.ResponseWriter, r *http.Request) {
+	arch := r.PathValue("arch")
+	leafs, err := utils.GetLeafPackages(arch)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	sort := r.URL.Query().Get("sort")
+	utils.SortLeafPackages(leafs, sort)
+	renderPage(w, r, arch, leafPackages(arch, sort, leafs))
+}
+
+func ShowLeafPackagesFile(w http.ResponseWriter, r *http.Request) {
+	arch := r.PathValue("arch")
+	leafs, err := utils.GetLeafPackages(arch)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	utils.SortLeafPackages(leafs, r.URL.Query().Get("sort"))
+	pageName := r.URL.Path[strings.LastIndexByte(r.URL.Path, '/')+1:]
+	utils.LeafPackagesExport(w, pageName, leafs)
+}
+
+func ShowStatistics(w http.ResponseWriter, r *http.Request) {
+	arch := r.PathValue("arch")
+	stats, err := utils.GetStabilizationStatistics(arch, nil)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	renderPage(w, r, arch, statistics(arch, stats))
+}
+
+func ShowStatisticsJson(w http.ResponseWriter, r *http.Request) {
+	arch := r.PathValue("arch")
+	stats, err := utils.GetStabilizationStatistics(arch, nil)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(stats)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+func ShowMissingKeywords(w http.ResponseWriter, r *http.Request) {
+	arch := r.PathValue("arch")
+	missing, err := utils.GetMissingKeywords([]string{arch}, "")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	renderPage(w, r, arch, missingKeywords(arch, missing))
 }
@@ -5,70 +5,89 @@
 package arches
 
 import (
+	"soko/pkg/app/utils"
 	"soko/pkg/database"
 	"soko/pkg/models"
+
+	"github.com/go-pg/pg/v10"
 )
 
-// getStabilizedVersionsForArch returns the given number of recently
-// stabilized versions of a specific arch
-func getStabilizedVersionsForArch(arch string, n int) ([]*models.Version, error) {
+// getChangedVersions returns the versions of the given number of recent
+// keyword changes matching the given condition. In case the tree is shown
+// at a given commit, only the changes up to the commit are taken into
+// account and the versions are shown as they have been at the commit.
+func getChangedVersions(condition func(q *pg.Query) (*pg.Query, error), n int, at *models.Commit) ([]*models.Version, error) {
 	var updates []models.KeywordChange
-	err := database.DBCon.Model(&updates).
-		Relation("Version").
+	query := database.DBCon.Model(&updates).
 		Relation("Commit").
 		Order("commit.preceding_commits DESC").
-		Where("stabilized::jsonb @> ?", "\""+arch+"\"").
-		Where("version.id IS NOT NULL").
-		Limit(n).
-		Select()
-	if err != nil {
+		WhereGroup(condition).
+		Limit(n)
+	if at == nil {
+		query = query.Relation("Version").
+			Where("version.id IS NOT NULL")
+	} else {
+		validVersions := utils.ValidAt(database.DBCon.Model((*models.VersionHistory)(nil)), at).
+			ColumnExpr("1").
+			Where("version_history.version_id = keyword_change.version_id")
+		query = query.Where("commit.preceding_commits <= ?", at.PrecedingCommits).
+			Where("EXISTS (?)", validVersions)
+	}
+	if err := query.Select(); err != nil {
 		return nil, err
 	}
 
-	stabilizedVersions := make([]*models.Version, len(updates))
+	versionsAt := map[string]*models.Version{}
+	if at != nil && len(updates) > 0 {
+		ids := make([]string, len(updates))
+		for i, update := range updates {
+			ids[i] = update.VersionId
+		}
+		var rows []*models.VersionHistory
+		err := utils.ValidAt(database.DBCon.Model(&rows), at).
+			WhereIn("version_id IN (?)", ids).
+			Select()
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			versionsAt[row.VersionId] = row.ToVersion()
+		}
+	}
+
+	versions := make([]*models.Version, len(updates))
 	for i, update := range updates {
+		if at != nil {
+			update.Version = versionsAt[update.VersionId]
+		}
 		update.Version.Commits = []*models.Commit{update.Commit}
-		stabilizedVersions[i] = update.Version
+		versions[i] = update.Version
 	}
-	return stabilizedVersions, err
+	return versions, nil
+}
+
+// getStabilizedVersionsForArch returns the given number of recently
+// stabilized versions of a specific arch
+func getStabilizedVersionsForArch(arch string, n int, at *models.Commit) ([]*models.Version, error) {
+	return getChangedVersions(func(q *pg.Query) (*pg.Query, error) {
+		return q.Where("stabilized::jsonb @> ?", "\""+arch+"\""), nil
+	}, n, at)
 }
 
 // getKeywordedVersionsForArch returns the given number of recently
 // keyworded versions of a specific arch
-func getKeywordedVersionsForArch(arch string, n int) ([]*models.Version, error) {
-	var updates []models.KeywordChange
-	err := database.DBCon.Model(&updates).
-		Relation("Version").
-		Relation("Commit").
-		Order("commit.preceding_commits DESC").
-		Where("added::jsonb @> ?", "\""+arch+"\"").
-		Where("version.id IS NOT NULL").
-		Limit(n).
-		Select()
-	if err != nil {
-		return nil, err
-	}
-
-	keywordedVersions := make([]*models.Version, len(updates))
-	for i, update := range updates {
-		update.Version.Commits = []*models.Commit{update.Commit}
-		keywordedVersions[i] = update.Version
-	}
-	return keywordedVersions, err
+func getKeywordedVersionsForArch(arch string, n int, at *models.Commit) ([]*models.Version, error) {
+	return getChangedVersions(func(q *pg.Query) (*pg.Query, error) {
+		return q.Where("added::jsonb @> ?", "\""+arch+"\""), nil
+	}, n, at)
 }
 
-func getLeafPackagesForArch(arch string) ([]string, error) {
-	var atoms []string
-	atomsWithReverse := database.DBCon.Model((*models.ReverseDependency)(nil)).
-		Join("JOIN versions").JoinOn("reverse_dependency.reverse_dependency_atom = versions.atom").
-		Where("? = ANY(STRING_TO_ARRAY(keywords, ' '))", arch).
-		WhereOr("? = ANY(STRING_TO_ARRAY(keywords, ' '))", "~"+arch).
-		ColumnExpr("DISTINCT reverse_dependency.atom")
-	err := database.DBCon.Model((*models.Version)(nil)).
-		Where("((? = ANY(STRING_TO_ARRAY(keywords, ' '))) OR (? = ANY(STRING_TO_ARRAY(keywords, ' ')))) AND (atom NOT IN (?))",
-			arch, "~"+arch, atomsWithReverse).
-		Order("atom").
-		ColumnExpr("DISTINCT atom").
-		Select(&atoms)
-	return atoms, err
+// getDroppedVersionsForArch returns the given number of versions whose
+// keyword of a specific arch has recently been dropped or destabilized
+func getDroppedVersionsForArch(arch string, n int, at *models.Commit) ([]*models.Version, error) {
+	return getChangedVersions(func(q *pg.Query) (*pg.Query, error) {
+		return q.Where("dropped::jsonb @> ?", "\""+arch+"\"").
+			WhereOr("dropped::jsonb @> ?", "\"~"+arch+"\"").
+			WhereOr("destabilized::jsonb @> ?", "\"~"+arch+"\""), nil
+	}, n, at)
 }
@@ -49,8 +49,18 @@ func ShowPackages(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	at, err := utils.ParseAt(r)
+	if err != nil {
+		utils.AtError(w, err)
+		return
+	}
+
 	var packages []packageInfo
-	err = database.DBCon.Model((*models.Version)(nil)).
+	query := database.DBCon.Model((*models.Version)(nil))
+	if at != nil {
+		query = utils.ValidAt(database.DBCon.Model((*models.VersionHistory)(nil)), at)
+	}
+	err = query.
 		DistinctOn("package").
 		Column("package", "description").
 		Where("category = ?", categoryName).
@@ -61,7 +71,7 @@ func ShowPackages(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	renderShowPage(w, r, "Packages", &category,
-		showPackages(categoryName, packages))
+		showPackages(categoryName, packages, at))
 }
 
 func ShowOutdated(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,61 @@
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Used to serve the database dumps
+
+package dumps
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"soko/pkg/app/layout"
+	"soko/pkg/config"
+	"soko/pkg/models"
+)
+
+// readManifest reads the manifest of the current dump,
+// which is nil in case no dump has been generated yet
+func readManifest() (*models.DumpManifest, error) {
+	b, err := os.ReadFile(filepath.Join(config.DumpsDir(), models.DumpManifestName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var manifest models.DumpManifest
+	return &manifest, json.Unmarshal(b, &manifest)
+}
+
+// Index renders a template listing the files of the current
+// dump, their checksums and the documentation of the tables
+func Index(w http.ResponseWriter, r *http.Request) {
+	manifest, err := readManifest()
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	layout.Layout("Database dumps", layout.About, index(manifest)).Render(r.Context(), w)
+}
+
+// Download serves a file of the current dump. Only the manifest and
+// the files listed in it are served.
+func Download(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("file")
+	if name != models.DumpManifestName {
+		manifest, err := readManifest()
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if manifest == nil || !slices.ContainsFunc(manifest.Files, func(file *models.DumpFile) bool {
+			return file.Name == name
+		}) {
+			http.NotFound(w, r)
+			return
+		}
+	}
+	http.ServeFile(w, r, filepath.Join(config.DumpsDir(), name))
+}
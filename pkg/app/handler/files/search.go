@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Used to find the packages installing a file
+
+package files
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"soko/pkg/app/layout"
+	"soko/pkg/database"
+)
+
+// maxOwners is the maximum number of owners returned
+const maxOwners = 100
+
+// fileOwner is a package installing a path, together
+// with the versions of the package installing it
+type fileOwner struct {
+	Path     string   `json:"path"`
+	Atom     string   `json:"atom"`
+	Type     string   `json:"type"`
+	Target   string   `json:"target,omitempty"`
+	Versions []string `json:"versions" pg:",array"`
+}
+
+// getFileOwners returns the packages installing the given path. In
+// case the path is no absolute path, the packages installing a file
+// with the given name are returned, e.g. the packages providing a
+// command.
+func getFileOwners(path string) ([]*fileOwner, error) {
+	var owners []*fileOwner
+	_, err := database.DBCon.Query(&owners, `SELECT path, atom, type, target, ARRAY_AGG(version ORDER BY version) AS versions
+		FROM package_files
+		WHERE CASE WHEN ?1 THEN path = ?0 ELSE name = ?0 END
+		GROUP BY path, atom, type, target
+		ORDER BY path, atom
+		LIMIT ?2`, path, strings.HasPrefix(path, "/"), maxOwners)
+	return owners, err
+}
+
+// Search renders the packages installing the path given by the path parameter
+func Search(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimSpace(r.URL.Query().Get("path"))
+	var owners []*fileOwner
+	if path != "" {
+		var err error
+		owners, err = getFileOwners(path)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+	}
+	layout.Layout("Files", layout.Packages, search(path, owners)).Render(r.Context(), w)
+}
+
+// SearchJson returns the packages installing the path given by the path parameter as json
+func SearchJson(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimSpace(r.URL.Query().Get("path"))
+	owners := []*fileOwner{}
+	if path != "" {
+		var err error
+		owners, err = getFileOwners(path)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	b, err := json.Marshal(owners)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
@@ -0,0 +1,262 @@
+// SPDX-License-Identifier: GPL-2.0-only
+package maintainer
+
+import (
+	"cmp"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"soko/pkg/app/handler/packages/components"
+	"soko/pkg/app/layout"
+	"soko/pkg/database"
+	"soko/pkg/models"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// dashboardKinds are the kinds of dashboard items, most urgent first
+var dashboardKinds = []string{
+	"security",
+	"last-rites",
+	"pkgcheck-error",
+	"outdated",
+	"stable-request",
+	"pull-request",
+	"deprecated",
+	"pkgcheck-warning",
+}
+
+// dashboardItem is something needing the attention of the maintainer
+type dashboardItem struct {
+	Kind     string `json:"kind"`
+	Priority int    `json:"priority"`
+	Atom     string `json:"atom"`
+	Version  string `json:"version,omitempty"`
+	Title    string `json:"title"`
+	Link     string `json:"link"`
+}
+
+func newDashboardItem(kind, atom, version, title, link string) *dashboardItem {
+	return &dashboardItem{
+		Kind:     kind,
+		Priority: slices.Index(dashboardKinds, kind) + 1,
+		Atom:     atom,
+		Version:  version,
+		Title:    title,
+		Link:     link,
+	}
+}
+
+// deprecatedClasses are the pkgcheck classes reporting the use of
+// deprecated or banned EAPIs and eclasses
+var deprecatedClasses = []string{"DeprecatedEapi", "BannedEapi", "DeprecatedEclass", "BannedEclass"}
+
+// pkgcheckKind returns the dashboard kind of a pkgcheck result of the
+// given class and level, or an empty string in case it is not shown.
+// Results of info or style level aren't shown, and results of an
+// unknown level are treated as warnings.
+func pkgcheckKind(class, level string) string {
+	switch {
+	case class == "StableRequest":
+		return "stable-request"
+	case slices.Contains(deprecatedClasses, class):
+		return "deprecated"
+	case level == "error":
+		return "pkgcheck-error"
+	case level == "info" || level == "style":
+		return ""
+	default:
+		return "pkgcheck-warning"
+	}
+}
+
+// removalDate matches the announcement of the removal date in the reason
+// of a last rites mask, following the 'Removal on YYYY-MM-DD' convention
+var removalDate = regexp.MustCompile(`(?i)\bremoval on (\d{4}-\d{2}-\d{2})\b`)
+
+// lastRitesDate returns the removal date announced in the reason
+// of a mask, or false in case the mask isn't a last rites mask
+func lastRitesDate(reason string) (string, bool) {
+	match := removalDate.FindStringSubmatch(reason)
+	if match == nil {
+		return "", false
+	}
+	return match[1], true
+}
+
+// sortDashboard sorts the items by priority, then by package and version
+func sortDashboard(items []*dashboardItem) {
+	slices.SortStableFunc(items, func(a, b *dashboardItem) int {
+		return cmp.Or(
+			cmp.Compare(a.Priority, b.Priority),
+			cmp.Compare(a.Atom, b.Atom),
+			cmp.Compare(a.Version, b.Version),
+		)
+	})
+}
+
+// getDashboard collects the items needing attention of
+// the packages selected by the given query
+func getDashboard(query *pg.Query) ([]*dashboardItem, error) {
+	var items []*dashboardItem
+
+	// the bugs of the versions are included, like in the counters of the maintainers
+	var securityBugs []struct {
+		PackageAtom string
+		Id          string
+		Summary     string
+	}
+	_, err := database.DBCon.Query(&securityBugs, `
+		SELECT DISTINCT package_bugs.atom AS package_atom, bugs.id, bugs.summary
+		FROM (`+models.PackageBugsQuery+`) AS package_bugs
+		JOIN bugs ON bugs.id = package_bugs.bug_id
+		WHERE bugs.component = ? AND package_bugs.atom IN (?)`,
+		models.BugComponentVulnerabilities, query)
+	if err != nil && err != pg.ErrNoRows {
+		return nil, err
+	}
+	for _, bug := range securityBugs {
+		items = append(items, newDashboardItem("security", bug.PackageAtom, "",
+			"Bug "+bug.Id+": "+bug.Summary, "https://bugs.gentoo.org/"+bug.Id))
+	}
+
+	var lastRites []struct {
+		Atom     string
+		Versions string
+		Reason   string
+	}
+	_, err = database.DBCon.Query(&lastRites, `
+		SELECT DISTINCT versions.atom, masks.versions, masks.reason
+		FROM masks
+		JOIN mask_to_versions ON mask_to_versions.mask_versions = masks.versions
+		JOIN versions ON versions.id = mask_to_versions.version_id
+		WHERE versions.atom IN (?) AND masks.reason ILIKE '%removal on%'`, query)
+	if err != nil && err != pg.ErrNoRows {
+		return nil, err
+	}
+	for _, mask := range lastRites {
+		date, found := lastRitesDate(mask.Reason)
+		if !found {
+			continue
+		}
+		reason, _, _ := strings.Cut(strings.TrimSpace(mask.Reason), "\n")
+		items = append(items, newDashboardItem("last-rites", mask.Atom, "",
+			mask.Versions+" is masked for removal on "+date+": "+reason, "/packages/"+mask.Atom))
+	}
+
+	var results []*models.PkgCheckResult
+	err = database.DBCon.Model(&results).
+		Column("atom", "version", "class", "level", "message").
+		Where("atom IN (?)", query).
+		Select()
+	if err != nil && err != pg.ErrNoRows {
+		return nil, err
+	}
+	for _, result := range results {
+		kind := pkgcheckKind(result.Class, result.Level)
+		switch kind {
+		case "":
+		case "stable-request":
+			items = append(items, newDashboardItem(kind, result.Atom, result.Version,
+				result.Message, "/packages/"+result.Atom))
+		default:
+			items = append(items, newDashboardItem(kind, result.Atom, result.Version,
+				result.Class+": "+result.Message, "/packages/"+result.Atom+"/qa-report"))
+		}
+	}
+
+	var outdated []*models.OutdatedPackages
+	err = database.DBCon.Model(&outdated).
+		Where("atom IN (?)", query).
+		Select()
+	if err != nil && err != pg.ErrNoRows {
+		return nil, err
+	}
+	for _, pkg := range outdated {
+		items = append(items, newDashboardItem("outdated", pkg.Atom, pkg.GentooVersion,
+			"Version "+pkg.NewestVersion+" is available upstream", "/packages/"+pkg.Atom))
+	}
+
+	var pullRequests []struct {
+		PackageAtom string
+		Id          string
+		Title       string
+	}
+	err = database.DBCon.Model((*models.PackageToPullRequest)(nil)).
+		DistinctOn("pull_request.id").
+		Column("package_to_pull_request.package_atom").
+		ColumnExpr("pull_request.id, pull_request.title").
+		Join("JOIN pull_requests AS pull_request ON pull_request.id = package_to_pull_request.pull_request_id").
+		Where("pull_request.closed IS NOT TRUE").
+		Where("package_to_pull_request.package_atom IN (?)", query).
+		OrderExpr("pull_request.id, package_to_pull_request.package_atom").
+		Select(&pullRequests)
+	if err != nil && err != pg.ErrNoRows {
+		return nil, err
+	}
+	for _, pr := range pullRequests {
+		_, number, _ := strings.Cut(pr.Id, "/")
+		items = append(items, newDashboardItem("pull-request", pr.PackageAtom, "",
+			"Pull request #"+number+": "+pr.Title, components.PullRequestURL(pr.Id)))
+	}
+
+	sortDashboard(items)
+	return items, nil
+}
+
+// countDashboardKinds returns the number of items of each kind
+func countDashboardKinds(items []*dashboardItem) map[string]int {
+	counts := make(map[string]int, len(dashboardKinds))
+	for _, item := range items {
+		counts[item.Kind]++
+	}
+	return counts
+}
+
+func ShowDashboard(w http.ResponseWriter, r *http.Request) {
+	maintainer, query, packagesCount, includeProjects, err := common(w, r)
+	if err != nil {
+		return
+	}
+	items, err := getDashboard(query)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	layout.Layout(maintainer.Name, layout.Maintainers,
+		show(packagesCount, &maintainer, "Dashboard", includeProjects, dashboard(&maintainer, includeProjects, items)),
+	).Render(r.Context(), w)
+}
+
+func ShowDashboardJson(w http.ResponseWriter, r *http.Request) {
+	maintainer, query, _, _, err := common(w, r)
+	if err != nil {
+		return
+	}
+	items, err := getDashboard(query)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if items == nil {
+		items = []*dashboardItem{}
+	}
+
+	reply := struct {
+		Maintainer string           `json:"maintainer"`
+		Counts     map[string]int   `json:"counts"`
+		Items      []*dashboardItem `json:"items"`
+	}{
+		Maintainer: maintainer.Email,
+		Counts:     countDashboardKinds(items),
+		Items:      items,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(reply)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,73 @@
+// SPDX-License-Identifier: GPL-2.0-only
+package maintainer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPkgcheckKind(t *testing.T) {
+	tests := []struct {
+		class    string
+		level    string
+		expected string
+	}{
+		{"StableRequest", "info", "stable-request"},
+		{"DeprecatedEclass", "warning", "deprecated"},
+		{"BannedEapi", "error", "deprecated"},
+		{"NonexistentDeps", "error", "pkgcheck-error"},
+		{"RedundantVersion", "info", ""},
+		{"VariableOrderWrong", "style", ""},
+		{"MissingSlotDep", "warning", "pkgcheck-warning"},
+		{"SomeNewCheck", "", "pkgcheck-warning"},
+	}
+	for _, tc := range tests {
+		if got := pkgcheckKind(tc.class, tc.level); got != tc.expected {
+			t.Errorf("pkgcheckKind(%q, %q) = %q, expected %q", tc.class, tc.level, got, tc.expected)
+		}
+	}
+}
+
+func TestSortDashboard(t *testing.T) {
+	items := []*dashboardItem{
+		newDashboardItem("pkgcheck-warning", "app-misc/a", "1", "", ""),
+		newDashboardItem("outdated", "dev-libs/b", "", "", ""),
+		newDashboardItem("security", "dev-libs/b", "", "", ""),
+		newDashboardItem("outdated", "app-misc/a", "", "", ""),
+	}
+	sortDashboard(items)
+
+	var got [][2]string
+	for _, item := range items {
+		got = append(got, [2]string{item.Kind, item.Atom})
+	}
+	expected := [][2]string{
+		{"security", "dev-libs/b"},
+		{"outdated", "app-misc/a"},
+		{"outdated", "dev-libs/b"},
+		{"pkgcheck-warning", "app-misc/a"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestLastRitesDate(t *testing.T) {
+	tests := []struct {
+		reason   string
+		date     string
+		expected bool
+	}{
+		{"Unmaintained, fails to build.\nRemoval on 2024-03-01.  Bug #123456.", "2024-03-01", true},
+		{"Dead upstream. removal on 2025-11-30", "2025-11-30", true},
+		{"Masked for removal in 30 days.", "", false},
+		{"Breaks the removal of old kernels.", "", false},
+		{"Removal on 2024-3-1.", "", false},
+	}
+	for _, tc := range tests {
+		date, found := lastRitesDate(tc.reason)
+		if date != tc.date || found != tc.expected {
+			t.Errorf("lastRitesDate(%q) = %q, %t, expected %q, %t", tc.reason, date, found, tc.date, tc.expected)
+		}
+	}
+}
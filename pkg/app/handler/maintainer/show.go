@@ -33,15 +33,32 @@ func common(w http.ResponseWriter, r *http.Request) (maintainer models.Maintaine
 	}
 
 	maintainer = models.Maintainer{Email: maintainerEmail}
-	err = database.DBCon.Model(&maintainer).WherePK().Relation("Project").Relation("Projects").Select()
+	err = database.DBCon.Model(&maintainer).WherePK().Relation("Project").Relation("Projects").Relation("Developer").Select()
 	if err != nil {
 		http.NotFound(w, r)
 		return
 	}
 
 	if includeProjects {
-		for _, proj := range maintainer.Projects {
-			packagesQuery = packagesQuery.WhereOr("maintainers @> ?", `[{"Email": "`+proj.Email+`"}]`)
+		// follow the subprojects inheriting the members of the projects
+		var roots []string
+		if maintainer.Type == "project" {
+			roots = []string{maintainer.Email}
+		} else {
+			for _, proj := range maintainer.Projects {
+				roots = append(roots, proj.Email)
+			}
+		}
+		var allProjects []*models.Project
+		err = database.DBCon.Model(&allProjects).Column("email", "subprojects").Select()
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		for _, email := range models.InheritingSubprojects(allProjects, roots) {
+			if email != maintainer.Email {
+				packagesQuery = packagesQuery.WhereOr("maintainers @> ?", `[{"Email": "`+email+`"}]`)
+			}
 		}
 	}
 
@@ -54,6 +71,50 @@ func common(w http.ResponseWriter, r *http.Request) (maintainer models.Maintaine
 	return
 }
 
+// developerInfo is the entry of the developer directory in the info.json
+type developerInfo struct {
+	Nickname     string     `json:"nickname"`
+	Name         string     `json:"name"`
+	Location     string     `json:"location"`
+	Joined       *time.Time `json:"joined"`
+	Retired      bool       `json:"retired"`
+	RetiredSince *time.Time `json:"retired_since"`
+	Roles        []string   `json:"roles"`
+}
+
+// optionalTime returns nil for the zero time
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// getProjectHierarchy returns the subprojects of the given
+// project as well as the projects it is a subproject of
+func getProjectHierarchy(project *models.Project) (subprojects, parents []*models.Project, err error) {
+	if len(project.Subprojects) > 0 {
+		emails := make([]string, 0, len(project.Subprojects))
+		for _, subproject := range project.Subprojects {
+			emails = append(emails, subproject.Email)
+		}
+		err = database.DBCon.Model(&subprojects).
+			Column("email", "name").
+			WhereIn("email IN (?)", emails).
+			Order("name").
+			Select()
+		if err != nil {
+			return
+		}
+	}
+	err = database.DBCon.Model(&parents).
+		Column("email", "name").
+		Where("subprojects @> ?", `[{"Email": "`+project.Email+`"}]`).
+		Order("name").
+		Select()
+	return
+}
+
 func ShowChangelog(w http.ResponseWriter, r *http.Request) {
 	maintainer, query, packagesCount, includeProjects, err := common(w, r)
 	if err != nil {
@@ -309,11 +370,51 @@ func ShowPackages(w http.ResponseWriter, r *http.Request) {
 		http.NotFound(w, r)
 		return
 	}
+	var subprojects, parents []*models.Project
+	if maintainer.Type == "project" {
+		subprojects, parents, err = getProjectHierarchy(&maintainer.Project)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+	}
+	layout.Layout(maintainer.Name, layout.Maintainers,
+		show(packagesCount, &maintainer, "Packages", includeProjects, showPackages(gpackages, &maintainer, subprojects, parents)),
+	).Render(r.Context(), w)
+}
+
+func ShowStatistics(w http.ResponseWriter, r *http.Request) {
+	maintainer, query, packagesCount, includeProjects, err := common(w, r)
+	if err != nil {
+		return
+	}
+	stats, err := utils.GetStabilizationStatistics("", query)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
 	layout.Layout(maintainer.Name, layout.Maintainers,
-		show(packagesCount, &maintainer, "Packages", includeProjects, showPackages(gpackages, &maintainer)),
+		show(packagesCount, &maintainer, "Statistics", includeProjects, utils.StabilizationStatisticsTable(stats)),
 	).Render(r.Context(), w)
 }
 
+func ShowStatisticsJson(w http.ResponseWriter, r *http.Request) {
+	_, query, _, _, err := common(w, r)
+	if err != nil {
+		return
+	}
+	stats, err := utils.GetStabilizationStatistics("", query)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(stats)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
 func ShowInfoJson(w http.ResponseWriter, r *http.Request) {
 	maintainer, _, _, _, err := common(w, r)
 	if err != nil {
@@ -321,11 +422,14 @@ func ShowInfoJson(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var reply struct {
-		Email     string   `json:"email"`
-		Name      string   `json:"name"`
-		IsProject bool     `json:"is_project"`
-		Members   []string `json:"members"`
-		MemberOf  []string `json:"member_of"`
+		Email            string         `json:"email"`
+		Name             string         `json:"name"`
+		IsProject        bool           `json:"is_project"`
+		Members          []string       `json:"members"`
+		InheritedMembers []string       `json:"inherited_members"`
+		Subprojects      []string       `json:"subprojects"`
+		MemberOf         []string       `json:"member_of"`
+		Developer        *developerInfo `json:"developer,omitempty"`
 	}
 
 	reply.Email = maintainer.Email
@@ -335,6 +439,23 @@ func ShowInfoJson(w http.ResponseWriter, r *http.Request) {
 	for _, member := range maintainer.Project.Members {
 		reply.Members = append(reply.Members, member.Email)
 	}
+	for _, member := range maintainer.Project.InheritedMembers {
+		reply.InheritedMembers = append(reply.InheritedMembers, member.Email)
+	}
+	for _, subproject := range maintainer.Project.Subprojects {
+		reply.Subprojects = append(reply.Subprojects, subproject.Email)
+	}
+	if developer := maintainer.DeveloperEntry(); developer != nil {
+		reply.Developer = &developerInfo{
+			Nickname:     developer.Nickname,
+			Name:         developer.Name,
+			Location:     developer.Location,
+			Joined:       optionalTime(developer.Joined),
+			Retired:      developer.Retired,
+			RetiredSince: optionalTime(developer.RetiredSince),
+			Roles:        developer.Roles,
+		}
+	}
 	for _, project := range maintainer.Projects {
 		reply.MemberOf = append(reply.MemberOf, project.Email)
 	}
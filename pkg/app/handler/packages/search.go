@@ -20,17 +20,28 @@ type searchResults struct {
 	Name        string `json:"name"`
 	Category    string `json:"category"`
 	Description string `json:"description"`
+	Snippet     string `json:"-"`
 }
 
 // Search renders a template containing a list of search results
 // for a given query of packages
 func Search(w http.ResponseWriter, r *http.Request) {
 	searchTerm := getParameterValue("q", r)
+	searchQuery, err := parseSearchQuery(searchTerm)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// API consumers may disable the redirects to single results
+	redirect := getParameterValue("redirect", r) != "no"
 
 	switch {
 	case searchTerm == "":
 		http.Redirect(w, r, "/", http.StatusMovedPermanently)
 		return
+	case searchQuery.HasFilters():
+		// field filters are never a single maintainer, category or package
+	case !redirect:
 	case strings.Contains(searchTerm, "@"):
 		var maintainers []models.Maintainer
 		_ = database.DBCon.Model(&maintainers).Where("email = ?", searchTerm).Select()
@@ -54,6 +65,74 @@ func Search(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	page := newSearchPage(r, searchTerm)
+	page.Results, page.Total, err = searchPackages(searchQuery, page.Sort, page.Page)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError),
+			http.StatusInternalServerError)
+		return
+	}
+	if redirect && page.Total == 1 && page.Page == 1 {
+		http.Redirect(w, r, "/packages/"+page.Results[0].Category+"/"+page.Results[0].Name, http.StatusMovedPermanently)
+		return
+	}
+	if page.Total > 0 {
+		page.Facets, err = getSearchFacets(searchQuery)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError),
+				http.StatusInternalServerError)
+			return
+		}
+	}
+
+	layout.Layout(searchTerm, layout.Packages, search(page)).Render(r.Context(), w)
+}
+
+// SearchJson returns the search results for a given query of packages as json
+func SearchJson(w http.ResponseWriter, r *http.Request) {
+	searchTerm := getParameterValue("q", r)
+	searchQuery, err := parseSearchQuery(searchTerm)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page := newSearchPage(r, searchTerm)
+	response := struct {
+		Results []searchResults `json:"results"`
+		Total   int             `json:"total"`
+		Page    int             `json:"page"`
+		Pages   int             `json:"pages"`
+		Facets  []*searchFacet  `json:"facets"`
+	}{Results: []searchResults{}, Page: page.Page}
+	if searchQuery.Text != "" || searchQuery.HasFilters() {
+		response.Results, response.Total, err = searchPackages(searchQuery, page.Sort, page.Page)
+		if err == nil {
+			response.Facets, err = getSearchFacets(searchQuery)
+		}
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError),
+				http.StatusInternalServerError)
+			return
+		}
+		page.Total = response.Total
+		response.Pages = page.Pages()
+	}
+
+	b, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError),
+			http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// searchPackages returns the given page of the packages matching
+// the given query in the given order, as well as the total number
+// of matching packages
+func searchPackages(searchQuery *searchQuery, sort string, page int) ([]searchResults, int, error) {
 	var results []searchResults
 	descriptionQuery := database.DBCon.Model((*models.Version)(nil)).
 		Column("description").
@@ -63,31 +142,14 @@ func Search(w http.ResponseWriter, r *http.Request) {
 		Column("name", "category").
 		ColumnExpr("(?) AS description", descriptionQuery)
 
-	if strings.Contains(searchTerm, "*") {
-		// if the query contains wildcards
-		wildcardSearchTerm := strings.ReplaceAll(searchTerm, "*", "%")
-		query = query.
-			WhereOr("atom LIKE ?", wildcardSearchTerm).
-			WhereOr("name LIKE ?", wildcardSearchTerm)
-	} else {
-		// if the query contains no wildcards do a fuzzy search
-		query = BuildSearchQuery(query, searchTerm).
-			WhereOr("atom LIKE ?", "%"+searchTerm+"%")
-	}
-
-	err := query.OrderExpr("name <-> ?", searchTerm).
-		Select(&results)
+	total, err := searchQuery.order(searchQuery.snippet(searchQuery.apply(query)), sort).
+		Limit(searchPageSize).
+		Offset((page - 1) * searchPageSize).
+		SelectAndCount(&results)
 	if err != nil && err != pg.ErrNoRows {
-		http.Error(w, http.StatusText(http.StatusInternalServerError),
-			http.StatusInternalServerError)
-		return
+		return nil, 0, err
 	}
-	if len(results) == 1 {
-		http.Redirect(w, r, "/packages/"+results[0].Category+"/"+results[0].Name, http.StatusMovedPermanently)
-		return
-	}
-
-	layout.Layout(searchTerm, layout.Packages, search(searchTerm, results)).Render(r.Context(), w)
+	return results, total, nil
 }
 
 // Search renders a template containing a list of search results
@@ -95,12 +157,15 @@ func Search(w http.ResponseWriter, r *http.Request) {
 func SearchFeed(w http.ResponseWriter, r *http.Request) {
 
 	searchTerm := getParameterValue("q", r)
-	searchTerm = strings.ReplaceAll(searchTerm, "*", "")
+	searchQuery, err := parseSearchQuery(strings.ReplaceAll(searchTerm, "*", ""))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	var packages []models.Package
-	err := BuildSearchQuery(database.DBCon.Model(&packages), searchTerm).
+	err = searchQuery.order(searchQuery.apply(database.DBCon.Model(&packages)), getParameterValue("sort", r)).
 		Relation("Versions").
-		OrderExpr("name <-> ?", searchTerm).
 		Select()
 	if err != nil && err != pg.ErrNoRows {
 		http.Error(w, http.StatusText(http.StatusInternalServerError),
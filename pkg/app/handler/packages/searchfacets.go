@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Used to count the search results per facet
+
+package packages
+
+import (
+	"soko/pkg/app/utils"
+	"soko/pkg/database"
+	"soko/pkg/models"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// searchFacetsLimit is the maximum number of values shown per facet
+const searchFacetsLimit = 10
+
+// searchFacetValue is the number of results having the value
+type searchFacetValue struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// searchFacet is a field of the query language, together with
+// the number of results per value of the field
+type searchFacet struct {
+	Title  string             `json:"title"`
+	Field  string             `json:"field"`
+	Values []searchFacetValue `json:"values"`
+}
+
+// getSearchFacets counts the packages matching the search query per
+// category, maintainer type, arch and whether they have security bugs
+func getSearchFacets(searchQuery *searchQuery) ([]*searchFacet, error) {
+	matched := searchQuery.apply(database.DBCon.Model((*models.Package)(nil)).Column("package.atom"))
+
+	categories := &searchFacet{Title: "Category", Field: "category"}
+	_, err := database.DBCon.Query(&categories.Values, `SELECT category AS value, COUNT(*) AS count
+		FROM packages WHERE atom IN (?)
+		GROUP BY category
+		ORDER BY count DESC, category
+		LIMIT ?`, matched, searchFacetsLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	maintainerTypes := &searchFacet{Title: "Maintainer Type", Field: "maintainer-type"}
+	_, err = database.DBCon.Query(&maintainerTypes.Values, `SELECT COALESCE(m ->> 'Type', 'none') AS value, COUNT(DISTINCT p.atom) AS count
+		FROM packages AS p
+		LEFT JOIN LATERAL JSONB_ARRAY_ELEMENTS(COALESCE(p.maintainers, '[]')) AS m ON TRUE
+		WHERE p.atom IN (?)
+		GROUP BY 1
+		ORDER BY count DESC, value`, matched)
+	if err != nil {
+		return nil, err
+	}
+
+	var keyworded []searchFacetValue
+	_, err = database.DBCon.Query(&keyworded, `SELECT TRIM(LEADING '~' FROM keyword) AS value, COUNT(DISTINCT v.atom) AS count
+		FROM versions AS v
+		CROSS JOIN UNNEST(STRING_TO_ARRAY(v.keywords, ' ')) AS keyword
+		WHERE v.atom IN (?) AND keyword <> '' AND keyword NOT LIKE '-%'
+		GROUP BY 1`, matched)
+	if err != nil {
+		return nil, err
+	}
+	arches := &searchFacet{Title: "Available on", Field: "arch", Values: archFacetValues(keyworded, utils.ArchesToShow())}
+
+	var security int
+	_, err = database.DBCon.QueryOne(pg.Scan(&security), `SELECT COUNT(DISTINCT pb.package_atom)
+		FROM package_to_bugs AS pb
+		JOIN bugs AS b ON b.id = pb.bug_id
+		WHERE b.component = ? AND pb.package_atom IN (?)`, models.BugComponentVulnerabilities, matched)
+	if err != nil {
+		return nil, err
+	}
+	securityBugs := &searchFacet{Title: "Open Security Bugs", Field: "security"}
+	if security > 0 {
+		securityBugs.Values = []searchFacetValue{{Value: "yes", Count: security}}
+	}
+
+	return []*searchFacet{categories, maintainerTypes, arches, securityBugs}, nil
+}
+
+// archFacetValues returns the counts of the given arches in the given
+// order. As arch:amd64 matches stable keywords only, the values are the
+// testing keywords, which match both stable and testing keywords.
+func archFacetValues(keyworded []searchFacetValue, arches []string) []searchFacetValue {
+	counts := make(map[string]int, len(keyworded))
+	for _, value := range keyworded {
+		counts[value.Value] = value.Count
+	}
+	var values []searchFacetValue
+	for _, arch := range arches {
+		if count := counts[arch]; count > 0 {
+			values = append(values, searchFacetValue{Value: "~" + arch, Count: count})
+		}
+	}
+	return values
+}
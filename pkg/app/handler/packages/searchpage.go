@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Used to paginate the search results
+
+package packages
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+)
+
+// searchPageSize is the number of results per page
+const searchPageSize = 50
+
+// searchPage is a page of the results of a search
+type searchPage struct {
+	Query   string
+	Sort    string
+	Page    int
+	Total   int
+	Results []searchResults
+	Facets  []*searchFacet
+}
+
+// newSearchPage creates the page of the search given by the page and sort
+// parameters of the request, defaulting to the first page sorted by relevance
+func newSearchPage(r *http.Request, query string) *searchPage {
+	page, err := strconv.Atoi(getParameterValue("page", r))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	sort := getParameterValue("sort", r)
+	if !slices.Contains(searchSorts, sort) {
+		sort = searchSorts[0]
+	}
+	return &searchPage{Query: query, Sort: sort, Page: page}
+}
+
+// Pages returns the number of pages
+func (p *searchPage) Pages() int {
+	return (p.Total + searchPageSize - 1) / searchPageSize
+}
+
+// First returns the position of the first result of the page
+func (p *searchPage) First() int {
+	return min((p.Page-1)*searchPageSize+1, p.Total)
+}
+
+// Last returns the position of the last result of the page
+func (p *searchPage) Last() int {
+	return (p.Page-1)*searchPageSize + len(p.Results)
+}
+
+// Link returns the link to the given page of the search in the given order
+func (p *searchPage) Link(page int, sort string) string {
+	return searchLink(p.Query, sort, page)
+}
+
+// FacetLink returns the link to the search, restricted
+// to the results having the given value of the field
+func (p *searchPage) FacetLink(field, value string) string {
+	return searchLink(p.Query+" "+field+":"+value, p.Sort, 1)
+}
+
+func searchLink(query, sort string, page int) string {
+	parameters := url.Values{}
+	parameters.Set("q", query)
+	if sort != searchSorts[0] {
+		parameters.Set("sort", sort)
+	}
+	if page > 1 {
+		parameters.Set("page", strconv.Itoa(page))
+	}
+	// the results of a refined search are shown even if there is only one
+	parameters.Set("redirect", "no")
+	return "/packages/search?" + parameters.Encode()
+}
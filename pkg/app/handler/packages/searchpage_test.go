@@ -0,0 +1,45 @@
+// SPDX-License-Identifier: GPL-2.0-only
+package packages
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSearchPage(t *testing.T) {
+	testCases := []struct {
+		name                string
+		url                 string
+		total, results      int
+		page                int
+		sort                string
+		pages, first, last  int
+		nextLink, facetLink string
+	}{
+		{"defaults", "/packages/search?q=pdf", 120, 50, 1, "relevance", 3, 1, 50,
+			"/packages/search?page=2&q=pdf&redirect=no", "/packages/search?q=pdf+arch%3A~arm64&redirect=no"},
+		{"last page", "/packages/search?q=pdf&page=3&sort=name", 120, 20, 3, "name", 3, 101, 120,
+			"/packages/search?page=4&q=pdf&redirect=no&sort=name", "/packages/search?q=pdf+arch%3A~arm64&redirect=no&sort=name"},
+		{"invalid", "/packages/search?q=pdf&page=-1&sort=size", 0, 0, 1, "relevance", 0, 0, 0,
+			"/packages/search?page=2&q=pdf&redirect=no", "/packages/search?q=pdf+arch%3A~arm64&redirect=no"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			page := newSearchPage(httptest.NewRequest("GET", tc.url, nil), "pdf")
+			page.Total = tc.total
+			page.Results = make([]searchResults, tc.results)
+			if page.Page != tc.page || page.Sort != tc.sort {
+				t.Errorf("Expected page %d sorted by %s, got page %d sorted by %s", tc.page, tc.sort, page.Page, page.Sort)
+			}
+			if page.Pages() != tc.pages || page.First() != tc.first || page.Last() != tc.last {
+				t.Errorf("Expected %d pages showing %d—%d, got %d pages showing %d—%d", tc.pages, tc.first, tc.last, page.Pages(), page.First(), page.Last())
+			}
+			if got := page.Link(page.Page+1, page.Sort); got != tc.nextLink {
+				t.Errorf("Expected next link %s, got %s", tc.nextLink, got)
+			}
+			if got := page.FacetLink("arch", "~arm64"); got != tc.facetLink {
+				t.Errorf("Expected facet link %s, got %s", tc.facetLink, got)
+			}
+		})
+	}
+}
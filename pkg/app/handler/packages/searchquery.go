@@ -0,0 +1,258 @@
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Used to parse the query language of the search
+
+package packages
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"soko/pkg/database"
+	"soko/pkg/models"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// searchFilter is a field filter of a search, e.g. use:qt6
+type searchFilter struct {
+	Field string
+	Value string
+}
+
+// searchQuery is a parsed search string. Text contains the free
+// text, which is matched against the packages as before, while all
+// filters have to match as well.
+type searchQuery struct {
+	Text    string
+	Filters []searchFilter
+}
+
+// searchFields are the fields that can be used as filter. herd and
+// project are aliases of maintainer, which append @gentoo.org to
+// values without domain.
+var searchFields = map[string]bool{
+	"maintainer": true,
+	"herd":       true,
+	"project":    true,
+	"use":        true,
+	"arch":       true,
+	"license":    true,
+	"eapi":       true,
+	"masked":     true,
+	"outdated":   true,
+	"security":   true,
+	"category":   true,
+	"binary":     true,
+	// the type of the maintainers, or none for packages without maintainer
+	"maintainer-type": true,
+}
+
+// parseSearchQuery splits the search string into the free text and the
+// field filters. Terms of the form field:value with a known field are
+// filters, all other terms are free text.
+func parseSearchQuery(searchString string) (*searchQuery, error) {
+	query := &searchQuery{}
+	var text []string
+	for term := range strings.FieldsSeq(searchString) {
+		field, value, found := strings.Cut(term, ":")
+		field = strings.ToLower(field)
+		if !found || !searchFields[field] {
+			text = append(text, term)
+			continue
+		}
+		if value == "" {
+			return nil, fmt.Errorf("missing value of %s", field)
+		}
+		switch field {
+		case "masked", "outdated", "security", "binary":
+			if value != "yes" && value != "no" {
+				return nil, fmt.Errorf("invalid value %q of %s, expected yes or no", value, field)
+			}
+		case "herd", "project":
+			if !strings.Contains(value, "@") {
+				value += "@gentoo.org"
+			}
+			field = "maintainer"
+		}
+		query.Filters = append(query.Filters, searchFilter{Field: field, Value: value})
+	}
+	query.Text = strings.Join(text, " ")
+	return query, nil
+}
+
+// HasFilters reports whether the query contains any field filter
+func (s *searchQuery) HasFilters() bool {
+	return len(s.Filters) > 0
+}
+
+// apply restricts the given query on packages to the packages
+// matching the free text as well as all filters
+func (s *searchQuery) apply(query *pg.Query) *pg.Query {
+	if s.Text != "" {
+		query = query.WhereGroup(func(q *pg.Query) (*pg.Query, error) {
+			if strings.Contains(s.Text, "*") {
+				// if the query contains wildcards
+				wildcardSearchTerm := strings.ReplaceAll(s.Text, "*", "%")
+				return q.WhereOr("package.atom LIKE ?", wildcardSearchTerm).
+					WhereOr("package.name LIKE ?", wildcardSearchTerm), nil
+			}
+			// if the query contains no wildcards do a fuzzy and a full text search
+			return q.WhereGroup(func(q *pg.Query) (*pg.Query, error) {
+				return BuildSearchQuery(q, s.Text), nil
+			}).WhereOr("package.atom LIKE ?", "%"+s.Text+"%").
+				WhereOr("package_search.document @@ ?", s.tsQuery()), nil
+		})
+		if s.isFullText() {
+			query = query.Join("LEFT JOIN package_searches AS package_search ON package_search.atom = package.atom")
+		}
+	}
+
+	for _, filter := range s.Filters {
+		switch filter.Field {
+		case "maintainer":
+			marshal, _ := json.Marshal(filter.Value)
+			query = query.WhereGroup(func(q *pg.Query) (*pg.Query, error) {
+				return q.WhereOr("package.maintainers @> ?", `[{"Email": `+string(marshal)+`}]`).
+					WhereOr("package.maintainers @> ?", `[{"Name": `+string(marshal)+`}]`), nil
+			})
+		case "use":
+			query = query.Where(`EXISTS (SELECT 1 FROM versions AS v, JSONB_ARRAY_ELEMENTS_TEXT(v.useflags) AS flag
+				WHERE v.atom = package.atom AND LTRIM(flag, '+-') = ?)`, filter.Value)
+		case "arch":
+			// testing keywords match stable keywords as well, like ACCEPT_KEYWORDS
+			query = query.Where(`EXISTS (SELECT 1 FROM versions AS v
+				WHERE v.atom = package.atom AND STRING_TO_ARRAY(v.keywords, ' ') && ?)`,
+				pg.Array(slices.Compact([]string{filter.Value, strings.TrimPrefix(filter.Value, "~")})))
+		case "license":
+			query = query.Where(`EXISTS (SELECT 1 FROM versions AS v
+				WHERE v.atom = package.atom AND ? = ANY(REGEXP_SPLIT_TO_ARRAY(v.license, '\s+')))`, filter.Value)
+		case "eapi":
+			query = query.Where(`EXISTS (SELECT 1 FROM versions AS v
+				WHERE v.atom = package.atom AND v.eapi = ?)`, filter.Value)
+		case "masked":
+			query = query.Where(`? = EXISTS (SELECT 1 FROM versions AS v
+				JOIN mask_to_versions AS mv ON mv.version_id = v.id
+				WHERE v.atom = package.atom)`, filter.Value == "yes")
+		case "outdated":
+			query = query.Where(`? = EXISTS (SELECT 1 FROM outdated_packages AS o
+				WHERE o.atom = package.atom)`, filter.Value == "yes")
+		case "security":
+			query = query.Where(`? = EXISTS (SELECT 1 FROM package_to_bugs AS pb
+				JOIN bugs AS b ON b.id = pb.bug_id
+				WHERE pb.package_atom = package.atom AND b.component = ?)`, filter.Value == "yes", models.BugComponentVulnerabilities)
+		case "binary":
+			query = query.Where(`? = EXISTS (SELECT 1 FROM binary_packages AS bp
+				WHERE bp.atom = package.atom)`, filter.Value == "yes")
+		case "category":
+			query = query.Where("package.category = ?", filter.Value)
+		case "maintainer-type":
+			if filter.Value == "none" {
+				query = query.Where("COALESCE(JSONB_ARRAY_LENGTH(package.maintainers), 0) = 0")
+			} else {
+				marshal, _ := json.Marshal(filter.Value)
+				query = query.Where("package.maintainers @> ?", `[{"Type": `+string(marshal)+`}]`)
+			}
+		}
+	}
+	return query
+}
+
+// isFullText reports whether the free text is used for a full text
+// search, which is the case unless the free text contains wildcards
+func (s *searchQuery) isFullText() bool {
+	return s.Text != "" && !strings.Contains(s.Text, "*")
+}
+
+// tsQuery returns the free text as full text search query
+func (s *searchQuery) tsQuery() pg.Safe {
+	return pg.SafeQuery("WEBSEARCH_TO_TSQUERY('english', ?)", s.Text).Value()
+}
+
+// searchSorts are the orders the results can be sorted by
+var searchSorts = []string{"relevance", "name", "updated", "added"}
+
+// order sorts the results by the given order, that is by name, by the
+// last update, by the date the package has been added, or by relevance
+// otherwise. Relevance combines the rank of the full text search with
+// the similarity of the name to the free text. In case the query
+// consists of filters only, the results are sorted by atom instead.
+func (s *searchQuery) order(query *pg.Query, sort string) *pg.Query {
+	switch {
+	case sort == "name":
+		return query.Order("package.name", "package.atom")
+	case sort == "updated":
+		return query.OrderExpr(`(SELECT MAX(c.preceding_commits) FROM commit_to_packages AS cp
+			JOIN commits AS c ON c.id = cp.commit_id
+			WHERE cp.package_atom = package.atom) DESC NULLS LAST`).Order("package.atom")
+	case sort == "added":
+		return query.OrderExpr(`(SELECT MIN(c.preceding_commits) FROM commit_to_packages AS cp
+			JOIN commits AS c ON c.id = cp.commit_id
+			WHERE cp.package_atom = package.atom) DESC NULLS LAST`).Order("package.atom")
+	case s.Text == "":
+		return query.Order("package.atom")
+	case s.isFullText():
+		return query.OrderExpr("COALESCE(TS_RANK(package_search.document, ?), 0) + SIMILARITY(package.name, ?) DESC", s.tsQuery(), s.Text).
+			Order("package.atom")
+	}
+	return query.OrderExpr("package.name <-> ?", strings.ReplaceAll(s.Text, "*", ""))
+}
+
+// snippetStart and snippetStop enclose the matches in snippets
+const (
+	snippetStart = "\x02"
+	snippetStop  = "\x03"
+)
+
+// snippet selects a snippet of the descriptions of the package, in which
+// the matches of the full text search are enclosed by snippetStart and
+// snippetStop, in case the free text is used for a full text search
+func (s *searchQuery) snippet(query *pg.Query) *pg.Query {
+	if !s.isFullText() {
+		return query
+	}
+	descriptionQuery := database.DBCon.Model((*models.Version)(nil)).
+		Column("description").
+		Where("atom = package.atom").
+		Limit(1)
+	return query.ColumnExpr("TS_HEADLINE('english', COALESCE((?), '') || ' ' || COALESCE(package.longdescription, ''), ?, ?) AS snippet",
+		descriptionQuery, s.tsQuery(), "StartSel="+snippetStart+", StopSel="+snippetStop+", MinWords=15, MaxWords=35")
+}
+
+// snippetPart is a part of a snippet, which is either matched or not
+type snippetPart struct {
+	Text  string
+	Match bool
+}
+
+// splitSnippet splits the snippet into the matched and unmatched parts
+func splitSnippet(snippet string) []snippetPart {
+	var parts []snippetPart
+	for snippet != "" {
+		before, rest, found := strings.Cut(snippet, snippetStart)
+		if before != "" {
+			parts = append(parts, snippetPart{Text: before})
+		}
+		if !found {
+			break
+		}
+		match, after, _ := strings.Cut(rest, snippetStop)
+		if match != "" {
+			parts = append(parts, snippetPart{Text: match, Match: true})
+		}
+		snippet = after
+	}
+	return parts
+}
+
+// hasMatch reports whether any part of the snippet is matched
+func hasMatch(parts []snippetPart) bool {
+	for _, part := range parts {
+		if part.Match {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+// SPDX-License-Identifier: GPL-2.0-only
+package packages
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	testCases := []struct {
+		name     string
+		search   string
+		expected *searchQuery
+		err      bool
+	}{
+		{"text only", "gentoo sources", &searchQuery{Text: "gentoo sources"}, false},
+		{"filters only", "use:qt6 arch:~arm64", &searchQuery{Filters: []searchFilter{{"use", "qt6"}, {"arch", "~arm64"}}}, false},
+		{"combined", "python maintainer:python@gentoo.org eapi:8", &searchQuery{Text: "python", Filters: []searchFilter{{"maintainer", "python@gentoo.org"}, {"eapi", "8"}}}, false},
+		{"project alias", "project:kde", &searchQuery{Filters: []searchFilter{{"maintainer", "kde@gentoo.org"}}}, false},
+		{"herd with domain", "herd:proxy-maint@gentoo.org", &searchQuery{Filters: []searchFilter{{"maintainer", "proxy-maint@gentoo.org"}}}, false},
+		{"unknown field is text", "dev-lang/python:3.12", &searchQuery{Text: "dev-lang/python:3.12"}, false},
+		{"boolean", "masked:no OUTDATED:yes", &searchQuery{Filters: []searchFilter{{"masked", "no"}, {"outdated", "yes"}}}, false},
+		{"invalid boolean", "masked:maybe", nil, true},
+		{"binary", "binary:yes", &searchQuery{Filters: []searchFilter{{"binary", "yes"}}}, false},
+		{"missing value", "license:", nil, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseSearchQuery(tc.search)
+			if (err != nil) != tc.err {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			if !tc.err && !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("Expected %+v, got %+v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestSplitSnippet(t *testing.T) {
+	testCases := []struct {
+		name     string
+		snippet  string
+		expected []snippetPart
+	}{
+		{"empty", "", nil},
+		{"no match", "A PDF viewer", []snippetPart{{Text: "A PDF viewer"}}},
+		{"matches", "A \x02PDF\x03 \x02viewer\x03 for X", []snippetPart{{Text: "A "}, {Text: "PDF", Match: true}, {Text: " "}, {Text: "viewer", Match: true}, {Text: " for X"}}},
+		{"unterminated", "A \x02PDF", []snippetPart{{Text: "A "}, {Text: "PDF", Match: true}}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := splitSnippet(tc.snippet)
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("Expected %+v, got %+v", tc.expected, got)
+			}
+		})
+	}
+}
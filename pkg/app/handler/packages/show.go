@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"net/http"
 	"soko/pkg/app/layout"
+	"soko/pkg/app/utils"
 	"soko/pkg/database"
 	"soko/pkg/models"
 	"strings"
@@ -29,6 +30,12 @@ func Show(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	at, err := utils.ParseAt(r)
+	if err != nil {
+		utils.AtError(w, err)
+		return
+	}
+
 	var currentSubTab string
 
 	var gpackage models.Package
@@ -52,7 +59,10 @@ func Show(w http.ResponseWriter, r *http.Request) {
 			// here be dragons
 			const template = (`'%[1]s', (SELECT ARRAY_AGG("%[1]s") ` +
 				`FROM jsonb_array_elements(COALESCE(NULLIF(changed_files -> '%[1]s', 'null'), '[]')) AS "%[1]s" ` +
-				`WHERE "%[1]s" ->> 'Path' LIKE ?)`)
+				`WHERE "%[1]s" ->> 'Path' LIKE ?0 OR "%[1]s" ->> 'OldPath' LIKE ?0)`)
+			if at != nil {
+				q = q.Where("preceding_commits <= ?", at.PrecedingCommits)
+			}
 			return q.Column("commit_to_package.*",
 				"commit.id", "preceding_commits", "message",
 				"author_name", "author_email", "author_date",
@@ -60,11 +70,16 @@ func Show(w http.ResponseWriter, r *http.Request) {
 				ColumnExpr(("json_build_object(" +
 					fmt.Sprintf(template, "Modified") + "," +
 					fmt.Sprintf(template, "Added") + "," +
-					fmt.Sprintf(template, "Deleted") +
-					") AS changed_files"), atom+"/%", atom+"/%", atom+"/%").
+					fmt.Sprintf(template, "Deleted") + "," +
+					fmt.Sprintf(template, "Renamed") +
+					") AS changed_files"), atom+"/%").
 				Order("preceding_commits DESC").
 				Limit(50), nil
+		}).Relation("Commits.KeywordChanges", func(q *pg.Query) (*pg.Query, error) {
+			return q.Where("package_id = ?", atom), nil
 		})
+	case "timeline":
+		currentSubTab = "Timeline"
 	case "changelog.json":
 		changelogJSON(w, r)
 		return
@@ -97,14 +112,31 @@ func Show(w http.ResponseWriter, r *http.Request) {
 	case "", "overview":
 		query = query.Relation("Outdated").
 			Relation("Versions.Masks").
-			Relation("Versions.Deprecates")
+			Relation("Versions.Deprecates").
+			Relation("Versions.BinaryPackages", func(q *pg.Query) (*pg.Query, error) {
+				return q.Order("build_time DESC"), nil
+			})
 		currentSubTab = "Overview"
 	default:
 		http.NotFound(w, r)
 		return
 	}
 
-	err := query.Where("atom = ?", atom).Select()
+	// only the versions and the changelog can be shown at a given commit
+	if at != nil && currentSubTab != "Overview" && currentSubTab != "Changelog" {
+		http.Error(w, "The "+currentSubTab+" tab can't be shown at a given commit", http.StatusBadRequest)
+		return
+	}
+
+	err = query.Where("atom = ?", atom).Select()
+	if at != nil {
+		if err != nil {
+			// the package might have been removed in the meantime
+			gpackage = models.Package{Atom: atom, Category: category, Name: packageName}
+		}
+		gpackage.Outdated = nil
+		gpackage.Versions, err = utils.VersionsAt(atom, at)
+	}
 	if err != nil || len(gpackage.Versions) == 0 {
 		var pkgmove models.PkgMove
 		err = database.DBCon.Model(&pkgmove).Where("source = ?", atom).Select()
@@ -119,7 +151,23 @@ func Show(w http.ResponseWriter, r *http.Request) {
 
 	sortVersionsDesc(gpackage.Versions)
 
-	layout.Layout(gpackage.Atom, layout.Packages, show(&gpackage, currentSubTab)).Render(r.Context(), w)
+	var lifecycles []*utils.VersionLifecycle
+	var missing []*utils.MissingKeyword
+	var retired []*models.Developer
+	switch currentSubTab {
+	case "Timeline":
+		lifecycles, err = utils.GetVersionLifecycles(gpackage.Atom)
+	case "Dependencies":
+		missing, err = utils.GetMissingKeywords(utils.AllArches(), gpackage.Atom)
+	case "Overview":
+		retired, err = getRetiredMaintainers(gpackage.Maintainers)
+	}
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	layout.Layout(gpackage.Atom, layout.Packages, show(&gpackage, currentSubTab, at, lifecycles, missing, retired)).Render(r.Context(), w)
 }
 
 // changelog renders a json version of the changelog
@@ -184,6 +232,43 @@ func changelogJSON(w http.ResponseWriter, r *http.Request) {
 	w.Write(b)
 }
 
+// getRetiredMaintainers returns the developer directory
+// entries of the retired maintainers of the package
+func getRetiredMaintainers(maintainers []*models.Maintainer) ([]*models.Developer, error) {
+	var emails []string
+	for _, maintainer := range maintainers {
+		emails = append(emails, strings.ToLower(maintainer.Email))
+	}
+	var retired []*models.Developer
+	if len(emails) == 0 {
+		return retired, nil
+	}
+	err := database.DBCon.Model(&retired).
+		WhereIn("LOWER(email) IN (?)", emails).
+		Where("retired").
+		Select()
+	return retired, err
+}
+
+// onlyRetiredMaintainers reports whether all distinct maintainers of
+// the package are retired developers. A package may list a maintainer
+// several times, e.g. with different types or descriptions.
+func onlyRetiredMaintainers(maintainers []*models.Maintainer, retired []*models.Developer) bool {
+	if len(maintainers) == 0 {
+		return false
+	}
+	retiredEmails := make(map[string]bool, len(retired))
+	for _, developer := range retired {
+		retiredEmails[strings.ToLower(developer.Email)] = true
+	}
+	for _, maintainer := range maintainers {
+		if !retiredEmails[strings.ToLower(maintainer.Email)] {
+			return false
+		}
+	}
+	return true
+}
+
 func countBugs(gpackage *models.Package) (securityBugs, nonSecurityBugs int) {
 	for _, bug := range gpackage.Bugs {
 		if bug.Component == string(models.BugComponentVulnerabilities) {
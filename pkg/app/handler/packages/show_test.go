@@ -0,0 +1,31 @@
+// SPDX-License-Identifier: GPL-2.0-only
+package packages
+
+import (
+	"soko/pkg/models"
+	"testing"
+)
+
+func TestOnlyRetiredMaintainers(t *testing.T) {
+	larry := &models.Maintainer{Email: "larry@gentoo.org"}
+	retired := []*models.Developer{{Email: "larry@gentoo.org", Retired: true}}
+	testCases := []struct {
+		name        string
+		maintainers []*models.Maintainer
+		retired     []*models.Developer
+		expected    bool
+	}{
+		{"no maintainers", nil, nil, false},
+		{"retired", []*models.Maintainer{larry}, retired, true},
+		{"listed twice", []*models.Maintainer{larry, {Email: "Larry@gentoo.org", Type: "person"}}, retired, true},
+		{"active maintainer", []*models.Maintainer{larry, {Email: "foo@gentoo.org"}}, retired, false},
+		{"active duplicates", []*models.Maintainer{{Email: "foo@gentoo.org"}, {Email: "foo@gentoo.org"}}, retired, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := onlyRetiredMaintainers(tc.maintainers, tc.retired); got != tc.expected {
+				t.Errorf("Expected %t, got %t", tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Used to build the template of a stabilization bug
+
+package packages
+
+import (
+	"net/url"
+	"slices"
+	"soko/pkg/models"
+	"strings"
+)
+
+// stabilizationRequest is the content of a stabilization bug
+type stabilizationRequest struct {
+	Summary string
+	// Atoms are the lines of the cf_stabilisation_atoms field
+	Atoms    []string
+	Assignee string
+	CC       []string
+	Warnings []string
+}
+
+// BugzillaURL returns the link to file the stabilization bug
+func (s *stabilizationRequest) BugzillaURL() string {
+	params := url.Values{
+		"product":                {"Gentoo Linux"},
+		"component":              {"Stabilization"},
+		"short_desc":             {s.Summary},
+		"cf_stabilisation_atoms": {strings.Join(s.Atoms, "\n")},
+		"assigned_to":            {s.Assignee},
+		"cc":                     {strings.Join(s.CC, ", ")},
+	}
+	return "https://bugs.gentoo.org/enter_bug.cgi?" + params.Encode()
+}
+
+// buildStabilizationRequest builds the stabilization bug of the versions
+// for the given arches. Versions are only requested for arches they are
+// keyworded testing on. stableArches maps the atoms of dependencies to
+// the arches any version of them is stable on, so that dependencies that
+// would need to be stabilized first are reported as warnings.
+func buildStabilizationRequest(versions []*models.Version, arches []string, maintainers map[string][]*models.Maintainer, stableArches map[string][]string) *stabilizationRequest {
+	request := &stabilizationRequest{}
+	requested := map[string][]string{}
+	var summaryAtoms []string
+
+	for _, version := range versions {
+		keywords := strings.Fields(version.Keywords)
+		var versionArches []string
+		for _, arch := range arches {
+			switch {
+			case slices.Contains(keywords, arch):
+				request.Warnings = append(request.Warnings, "="+version.Id+" is already stable on "+arch)
+			case slices.Contains(keywords, "~"+arch):
+				versionArches = append(versionArches, arch)
+			default:
+				request.Warnings = append(request.Warnings, "="+version.Id+" is not keyworded on "+arch+", a keywording request is needed first")
+			}
+		}
+		if len(versionArches) == 0 {
+			continue
+		}
+		requested[version.Atom] = append(requested[version.Atom], versionArches...)
+		request.Atoms = append(request.Atoms, "="+version.Id+" "+strings.Join(versionArches, " "))
+		summaryAtoms = append(summaryAtoms, "="+version.Id)
+
+		for _, maintainer := range maintainers[version.Atom] {
+			if request.Assignee == "" {
+				request.Assignee = maintainer.Email
+			} else if maintainer.Email != request.Assignee && !slices.Contains(request.CC, maintainer.Email) {
+				request.CC = append(request.CC, maintainer.Email)
+			}
+		}
+	}
+
+	for _, version := range versions {
+		for _, arch := range requested[version.Atom] {
+			for _, dependency := range version.Dependencies {
+				if dependency.Atom == version.Atom || slices.Contains(stableArches[dependency.Atom], arch) ||
+					slices.Contains(requested[dependency.Atom], arch) {
+					continue
+				}
+				warning := "=" + version.Id + " depends on " + dependency.Atom + " (" + dependency.Type
+				if dependency.Condition != "" {
+					warning += " if " + dependency.Condition
+				}
+				warning += "), which has no version stable on " + arch
+				if !slices.Contains(request.Warnings, warning) {
+					request.Warnings = append(request.Warnings, warning)
+				}
+			}
+		}
+	}
+
+	var teams []string
+	for _, atomArches := range requested {
+		for _, arch := range atomArches {
+			if team := arch + "@gentoo.org"; !slices.Contains(teams, team) {
+				teams = append(teams, team)
+			}
+		}
+	}
+	slices.Sort(teams)
+	request.CC = append(request.CC, teams...)
+
+	if request.Assignee == "" {
+		request.Assignee = "maintainer-needed@gentoo.org"
+	}
+	if len(summaryAtoms) > 0 {
+		request.Summary = strings.Join(summaryAtoms, ", ") + ": stablereq"
+	}
+	return request
+}
@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: GPL-2.0-only
+package packages
+
+import (
+	"slices"
+	"soko/pkg/models"
+	"testing"
+)
+
+func TestBuildStabilizationRequest(t *testing.T) {
+	versions := []*models.Version{
+		{
+			Id: "dev-libs/foo-1.2", Atom: "dev-libs/foo", Keywords: "~amd64 ~arm64 x86",
+			Dependencies: []*models.ReverseDependency{
+				{Atom: "dev-libs/bar", Type: "RDEPEND"},
+				{Atom: "dev-libs/baz", Type: "DEPEND", Condition: "test"},
+				{Atom: "dev-libs/qux", Type: "RDEPEND"},
+			},
+		},
+		{Id: "dev-libs/qux-2.0", Atom: "dev-libs/qux", Keywords: "~amd64"},
+	}
+	maintainers := map[string][]*models.Maintainer{
+		"dev-libs/foo": {{Email: "larry@gentoo.org"}, {Email: "foo@gentoo.org"}},
+		"dev-libs/qux": {{Email: "larry@gentoo.org"}},
+	}
+	stableArches := map[string][]string{
+		"dev-libs/bar": {"amd64", "arm64"},
+		"dev-libs/baz": {"amd64"},
+	}
+
+	request := buildStabilizationRequest(versions, []string{"amd64", "arm64", "x86"}, maintainers, stableArches)
+
+	expectedAtoms := []string{"=dev-libs/foo-1.2 amd64 arm64", "=dev-libs/qux-2.0 amd64"}
+	if !slices.Equal(request.Atoms, expectedAtoms) {
+		t.Errorf("Expected atoms %q, got %q", expectedAtoms, request.Atoms)
+	}
+	if request.Summary != "=dev-libs/foo-1.2, =dev-libs/qux-2.0: stablereq" {
+		t.Errorf("Unexpected summary %q", request.Summary)
+	}
+	if request.Assignee != "larry@gentoo.org" {
+		t.Errorf("Unexpected assignee %q", request.Assignee)
+	}
+	expectedCC := []string{"foo@gentoo.org", "amd64@gentoo.org", "arm64@gentoo.org"}
+	if !slices.Equal(request.CC, expectedCC) {
+		t.Errorf("Expected CC %q, got %q", expectedCC, request.CC)
+	}
+	expectedWarnings := []string{
+		"=dev-libs/foo-1.2 is already stable on x86",
+		"=dev-libs/qux-2.0 is not keyworded on arm64, a keywording request is needed first",
+		"=dev-libs/qux-2.0 is not keyworded on x86, a keywording request is needed first",
+		"=dev-libs/foo-1.2 depends on dev-libs/baz (DEPEND if test), which has no version stable on arm64",
+		"=dev-libs/foo-1.2 depends on dev-libs/qux (RDEPEND), which has no version stable on arm64",
+	}
+	if !slices.Equal(request.Warnings, expectedWarnings) {
+		t.Errorf("Expected warnings\n%q, got\n%q", expectedWarnings, request.Warnings)
+	}
+}
@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Triggers an incremental update on pushes to the repository
+
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"soko/pkg/config"
+	"soko/pkg/database"
+	"soko/pkg/models"
+	"strings"
+	"time"
+)
+
+// maxPayloadSize is the maximum size of a push payload that is accepted
+const maxPayloadSize = 25 << 20
+
+type pushEvent struct {
+	Ref   string `json:"ref"`
+	After string `json:"after"`
+}
+
+// commitId matches the SHA-1 or SHA-256 id of a commit
+var commitId = regexp.MustCompile(`^[0-9a-f]{40}([0-9a-f]{24})?$`)
+
+// Push handles push webhooks sent by GitHub or Gitea. The payload has to be
+// signed using the configured secret. Pushes of new commits to the configured
+// ref are recorded as pending update, which is run by the scheduler daemon.
+func Push(w http.ResponseWriter, r *http.Request) {
+	secret := config.WebhookSecret()
+	if secret == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPayloadSize))
+	if err != nil {
+		http.Error(w, "Failed reading payload", http.StatusBadRequest)
+		return
+	}
+
+	signature := r.Header.Get("X-Hub-Signature-256")
+	if signature == "" {
+		signature = r.Header.Get("X-Gitea-Signature")
+	}
+	if !validSignature(secret, body, signature) {
+		slog.Warn("Rejected webhook with invalid signature", slog.String("remote", r.RemoteAddr))
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	event := r.Header.Get("X-GitHub-Event")
+	if event == "" {
+		event = r.Header.Get("X-Gitea-Event")
+	}
+	if event != "" && event != "push" {
+		// e.g. the ping sent when creating the webhook
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var push pushEvent
+	if err := json.Unmarshal(body, &push); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+	if push.Ref != config.WebhookRef() {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if !commitId.MatchString(push.After) {
+		http.Error(w, "Invalid commit", http.StatusBadRequest)
+		return
+	}
+	if strings.Trim(push.After, "0") == "" {
+		// the ref has been deleted, there is nothing to import
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	imported, err := database.DBCon.Model((*models.Commit)(nil)).Where("id = ?", push.After).Exists()
+	if err != nil {
+		slog.Error("Failed checking pushed commit", slog.String("commit", push.After), slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	} else if imported {
+		// e.g. a redelivered webhook
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	_, err = database.DBCon.Model(&models.PendingUpdate{
+		Ref:        push.Ref,
+		Commit:     push.After,
+		ReceivedAt: time.Now(),
+	}).Insert()
+	if err != nil {
+		slog.Error("Failed recording pending update", slog.String("commit", push.After), slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	slog.Info("Received push", slog.String("ref", push.Ref), slog.String("commit", push.After))
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// validSignature reports whether signature is the hex encoded HMAC-SHA256
+// of the body. GitHub prefixes the signature with "sha256=", Gitea doesn't.
+func validSignature(secret string, body []byte, signature string) bool {
+	given, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil || len(given) == 0 {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(given, mac.Sum(nil))
+}
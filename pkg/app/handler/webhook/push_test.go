@@ -0,0 +1,39 @@
+// SPDX-License-Identifier: GPL-2.0-only
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestValidSignature(t *testing.T) {
+	body := []byte(`{"ref":"refs/heads/master"}`)
+	testCases := []struct {
+		name      string
+		signature string
+		expected  bool
+	}{
+		{"github", "sha256=" + sign("secret", body), true},
+		{"gitea", sign("secret", body), true},
+		{"wrong secret", "sha256=" + sign("other", body), false},
+		{"wrong signature", "sha256=" + strings.Repeat("00", sha256.Size), false},
+		{"not hex", "sha256=xyz", false},
+		{"missing", "", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := validSignature("secret", body, tc.signature); got != tc.expected {
+				t.Errorf("Expected %t, got %t", tc.expected, got)
+			}
+		})
+	}
+}
+
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
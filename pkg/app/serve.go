@@ -13,10 +13,13 @@ import (
 	"soko/pkg/app/handler/about"
 	"soko/pkg/app/handler/arches"
 	"soko/pkg/app/handler/categories"
+	"soko/pkg/app/handler/dumps"
+	"soko/pkg/app/handler/files"
 	"soko/pkg/app/handler/index"
 	"soko/pkg/app/handler/maintainer"
 	"soko/pkg/app/handler/packages"
 	"soko/pkg/app/handler/useflags"
+	"soko/pkg/app/handler/webhook"
 	"soko/pkg/config"
 	"soko/pkg/database"
 	"time"
@@ -53,13 +56,30 @@ func Serve(staticAssets fs.FS) {
 	setRoute("GET /useflags/popular", useflags.PopularPage)
 	setRoute("GET /useflags/{useflag}", useflags.Show)
 
-	redirect("GET /arches", "/arches/amd64/keyworded")
+	setRoute("GET /arches", arches.ShowOverview)
+	setRoute("GET /arches/{$}", arches.ShowOverview)
 	setRoute("GET /arches/{arch}/stable", arches.ShowStable)
 	setRoute("GET /arches/{arch}/stable.atom", arches.ShowStableFeed)
 	setRoute("GET /arches/{arch}/{$}", arches.ShowKeyworded)
 	setRoute("GET /arches/{arch}/keyworded", arches.ShowKeyworded)
 	setRoute("GET /arches/{arch}/keyworded.atom", arches.ShowKeywordedFeed)
-	setRoute("GET /arches/{arch}/leaf-packages", arches.ShowLeafPackages)
+	setRoute("GET /arches/{arch}/dropped", arches.ShowDropped)
+	setRoute("GET /arches/{arch}/dropped.atom", arches.ShowDroppedFeed)
+	setRoute("GET /arches/{arch}/statistics", arches.ShowStatistics)
+	setRoute("GET /arches/{arch}/statistics.json", arches.ShowStatisticsJson)
+	setRoute("GET /arches/{arch}/missing-keywords", arches.ShowMissingKeywords)
+	setRoute("GET /arches/{arch}/leaf-packages", arches.ShowLeafPackagesList)
+	setRoute("GET /arches/{arch}/leaf-packages/report", arches.ShowLeafPackages)
+	setRoute("GET /arches/{arch}/leaf-packages.csv", arches.ShowLeafPackagesFile)
+	setRoute("GET /arches/{arch}/leaf-packages.json", arches.ShowLeafPackagesFile)
+	setRoute("GET /arches/{arch}/leaf-packages.list", arches.ShowLeafPackagesFile)
+
+	setRoute("GET /files", files.Search)
+	setRoute("GET /files.json", files.SearchJson)
+
+	redirect("GET /dumps", "/dumps/")
+	setRoute("GET /dumps/{$}", dumps.Index)
+	setRoute("GET /dumps/{file}", dumps.Download)
 
 	setRoute("GET /about", about.Index)
 	redirect("GET /about/feedback", "/about")
@@ -74,12 +94,16 @@ func Serve(staticAssets fs.FS) {
 	setRoute("GET /maintainer/{email}/{$}", maintainer.ShowPackages)
 	setRoute("GET /maintainer/{email}/bugs", maintainer.ShowBugs)
 	setRoute("GET /maintainer/{email}/changelog", maintainer.ShowChangelog)
+	setRoute("GET /maintainer/{email}/dashboard", maintainer.ShowDashboard)
+	setRoute("GET /maintainer/{email}/dashboard.json", maintainer.ShowDashboardJson)
 	setRoute("GET /maintainer/{email}/changelog.atom", maintainer.ShowChangelogFeed)
 	setRoute("GET /maintainer/{email}/info.json", maintainer.ShowInfoJson)
 	setRoute("GET /maintainer/{email}/outdated", maintainer.ShowOutdated)
 	setRoute("GET /maintainer/{email}/outdated.atom", maintainer.ShowOutdatedFeed)
 	setRoute("GET /maintainer/{email}/pull-requests", maintainer.ShowPullRequests)
 	setRoute("GET /maintainer/{email}/security", maintainer.ShowSecurity)
+	setRoute("GET /maintainer/{email}/statistics", maintainer.ShowStatistics)
+	setRoute("GET /maintainer/{email}/statistics.json", maintainer.ShowStatisticsJson)
 	setRoute("GET /maintainer/{email}/stabilization", maintainer.ShowStabilization)
 	setRoute("GET /maintainer/{email}/stabilization.json", maintainer.ShowStabilizationFile)
 	setRoute("GET /maintainer/{email}/stabilization.list", maintainer.ShowStabilizationFile)
@@ -88,6 +112,7 @@ func Serve(staticAssets fs.FS) {
 
 	setRoute("GET /packages/eapi7", packages.Eapi)
 	setRoute("GET /packages/search", packages.Search)
+	setRoute("GET /packages/search.json", packages.SearchJson)
 	setRoute("GET /packages/suggest.json", packages.Suggest)
 	setRoute("GET /packages/resolve.json", packages.Resolve)
 	setRoute("GET /packages/added", packages.Added)
@@ -99,6 +124,7 @@ func Serve(staticAssets fs.FS) {
 	setRoute("GET /packages/stabilization.list", packages.AllStableRequestsFile)
 	setRoute("GET /packages/stabilization.xml", packages.AllStableRequestsFile)
 	setRoute("GET /packages/stabilization.atom", packages.AllStableRequestsFeed)
+	setRoute("GET /packages/stabilization/request", packages.StabilizationRequest)
 	setRoute("GET /packages/{category}/{package}", packages.Show)
 	setRoute("GET /packages/{category}/{package}/{$}", packages.Show)
 	setRoute("GET /packages/{category}/{package}/{pageName}", packages.Show)
@@ -112,6 +138,8 @@ func Serve(staticAssets fs.FS) {
 	redirect("GET /packages/stabilized.atom", "/packages/stable.atom")
 	setRoute("GET /packages/search.atom", packages.SearchFeed)
 
+	setRoute("POST /webhook/push", webhook.Push)
+
 	fs := http.StripPrefix("/", http.FileServerFS(staticAssets))
 	http.Handle("/assets/", fs)
 
@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Contains utility functions to retrieve the arches of the tree
+
+package utils
+
+import (
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"soko/pkg/config"
+	"soko/pkg/database"
+	"soko/pkg/models"
+)
+
+// defaultArches are used until the arches have been imported
+var defaultArches = []string{"alpha", "amd64", "arm", "arm64", "hppa", "mips", "ppc", "ppc64", "riscv", "s390", "sparc", "x86"}
+
+// archCache caches the arches, as they only change with an update.
+// The cached slices are shared and must not be modified.
+var archCache struct {
+	sync.Mutex
+	arches       []*models.Arch
+	allArches    []string
+	archesToShow []string
+	expires      time.Time
+}
+
+// GetArches returns all arches of profiles/arch.list in the order of the file
+func GetArches() []*models.Arch {
+	loadArches()
+	return archCache.arches
+}
+
+// AllArches returns the names of all arches except for the prefix arches
+func AllArches() []string {
+	loadArches()
+	return archCache.allArches
+}
+
+// ArchesToShow returns the names of the arches shown in keyword tables,
+// that is all arches except for the prefix arches, with amd64 and x86 first
+func ArchesToShow() []string {
+	loadArches()
+	return archCache.archesToShow
+}
+
+// loadArches refreshes the cached arches in case they are expired
+func loadArches() {
+	archCache.Lock()
+	defer archCache.Unlock()
+	if time.Now().Before(archCache.expires) {
+		return
+	}
+
+	var arches []*models.Arch
+	err := database.DBCon.Model(&arches).Order("position").Select()
+	if err != nil {
+		slog.Error("Failed fetching arches", slog.Any("err", err))
+	}
+	if len(arches) == 0 {
+		arches = make([]*models.Arch, len(defaultArches))
+		for i, name := range defaultArches {
+			arches[i] = &models.Arch{Name: name, Position: i}
+		}
+	}
+
+	var allArches []string
+	for _, arch := range arches {
+		if !arch.IsPrefix() {
+			allArches = append(allArches, arch.Name)
+		}
+	}
+	archesToShow := slices.Clone(allArches)
+	slices.SortStableFunc(archesToShow, func(a, b string) int {
+		return archRank(a) - archRank(b)
+	})
+
+	archCache.arches = arches
+	archCache.allArches = allArches
+	archCache.archesToShow = archesToShow
+	archCache.expires = time.Now().Add(config.CacheTime)
+}
+
+func archRank(arch string) int {
+	switch arch {
+	case "amd64":
+		return 0
+	case "x86":
+		return 1
+	}
+	return 2
+}
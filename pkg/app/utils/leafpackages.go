@@ -0,0 +1,147 @@
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Contains utility functions to find leaf packages
+
+package utils
+
+import (
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"soko/pkg/database"
+	"soko/pkg/models"
+)
+
+// LeafPackage is a package keyworded on an arch, that no other
+// package keyworded on the arch depends on. LastTouch is the date
+// of the last commit touching the package, Bugs the number of open
+// bugs. Masked is set in case all versions of the package are masked.
+type LeafPackage struct {
+	Atom        string
+	Maintainers []*models.Maintainer
+	LastTouch   time.Time
+	Bugs        int
+	Masked      bool
+	Outdated    bool
+}
+
+// MaintainerEmails returns the emails of the maintainers
+func (l *LeafPackage) MaintainerEmails() []string {
+	emails := make([]string, len(l.Maintainers))
+	for i, maintainer := range l.Maintainers {
+		emails[i] = maintainer.Email
+	}
+	return emails
+}
+
+// GetLeafPackages returns the leaf packages of the given arch
+func GetLeafPackages(arch string) ([]*LeafPackage, error) {
+	var leafs []*LeafPackage
+	_, err := database.DBCon.Query(&leafs, `WITH keyworded AS (
+			SELECT DISTINCT atom FROM versions
+			WHERE ?0 = ANY(STRING_TO_ARRAY(keywords, ' ')) OR '~' || ?0 = ANY(STRING_TO_ARRAY(keywords, ' '))
+		)
+		SELECT p.atom, p.maintainers,
+			(SELECT MAX(c.committer_date) FROM commit_to_packages AS cp
+				JOIN commits AS c ON c.id = cp.commit_id
+				WHERE cp.package_atom = p.atom) AS last_touch,
+			(SELECT COUNT(*) FROM package_to_bugs AS pb WHERE pb.package_atom = p.atom) AS bugs,
+			NOT EXISTS (SELECT 1 FROM versions AS v
+				WHERE v.atom = p.atom AND NOT EXISTS (
+					SELECT 1 FROM mask_to_versions AS mv WHERE mv.version_id = v.id)) AS masked,
+			EXISTS (SELECT 1 FROM outdated_packages AS o WHERE o.atom = p.atom) AS outdated
+		FROM packages AS p
+		JOIN keyworded AS k ON k.atom = p.atom
+		WHERE NOT EXISTS (
+			SELECT 1 FROM reverse_dependencies AS rd
+			JOIN keyworded AS kr ON kr.atom = rd.reverse_dependency_atom
+			WHERE rd.atom = p.atom AND rd.reverse_dependency_atom <> p.atom)
+		ORDER BY p.atom`, arch)
+	return leafs, err
+}
+
+// SortLeafPackages sorts the leaf packages by the given key, that is
+// last-touch, bugs or maintainer. The packages are sorted by atom otherwise.
+// The least recently touched packages and the packages with the most
+// bugs are sorted first.
+func SortLeafPackages(leafs []*LeafPackage, key string) {
+	slices.SortStableFunc(leafs, func(a, b *LeafPackage) int {
+		var c int
+		switch key {
+		case "last-touch":
+			c = a.LastTouch.Compare(b.LastTouch)
+		case "bugs":
+			c = cmp.Compare(b.Bugs, a.Bugs)
+		case "maintainer":
+			c = cmp.Compare(strings.Join(a.MaintainerEmails(), " "), strings.Join(b.MaintainerEmails(), " "))
+		}
+		if c == 0 {
+			c = cmp.Compare(a.Atom, b.Atom)
+		}
+		return c
+	})
+}
+
+type leafPackageExport struct {
+	Atom        string    `json:"atom"`
+	Maintainers []string  `json:"maintainers"`
+	LastTouch   time.Time `json:"last_touch"`
+	Bugs        int       `json:"bugs"`
+	Masked      bool      `json:"masked"`
+	Outdated    bool      `json:"outdated"`
+}
+
+// LeafPackagesExport writes the leaf packages in the format given
+// by the extension of the pageUrl, that is json, csv or list
+func LeafPackagesExport(w http.ResponseWriter, pageUrl string, leafs []*LeafPackage) {
+	_, extension, _ := strings.Cut(pageUrl, ".")
+	switch extension {
+	case "json":
+		result := make([]leafPackageExport, len(leafs))
+		for i, leaf := range leafs {
+			result[i] = leafPackageExport{
+				Atom:        leaf.Atom,
+				Maintainers: leaf.MaintainerEmails(),
+				LastTouch:   leaf.LastTouch,
+				Bugs:        leaf.Bugs,
+				Masked:      leaf.Masked,
+				Outdated:    leaf.Outdated,
+			}
+		}
+		b, err := json.Marshal(result)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		writer := csv.NewWriter(w)
+		writer.Write([]string{"atom", "maintainers", "last_touch", "bugs", "masked", "outdated"})
+		for _, leaf := range leafs {
+			writer.Write([]string{
+				leaf.Atom,
+				strings.Join(leaf.MaintainerEmails(), " "),
+				leaf.LastTouch.Format(time.DateOnly),
+				strconv.Itoa(leaf.Bugs),
+				strconv.FormatBool(leaf.Masked),
+				strconv.FormatBool(leaf.Outdated),
+			})
+		}
+		writer.Flush()
+	case "list":
+		var lines string
+		for _, leaf := range leafs {
+			lines += leaf.Atom + "\n"
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(lines))
+	}
+}
@@ -0,0 +1,42 @@
+// SPDX-License-Identifier: GPL-2.0-only
+package utils
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"soko/pkg/models"
+)
+
+func TestSortLeafPackages(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	leafs := []*LeafPackage{
+		{Atom: "dev-libs/b", LastTouch: day(3), Bugs: 1, Maintainers: []*models.Maintainer{{Email: "z@gentoo.org"}}},
+		{Atom: "app-misc/c", LastTouch: day(1), Bugs: 0, Maintainers: []*models.Maintainer{{Email: "a@gentoo.org"}}},
+		{Atom: "app-misc/a", LastTouch: day(2), Bugs: 1},
+	}
+
+	testCases := []struct {
+		key      string
+		expected []string
+	}{
+		{"atom", []string{"app-misc/a", "app-misc/c", "dev-libs/b"}},
+		{"last-touch", []string{"app-misc/c", "app-misc/a", "dev-libs/b"}},
+		{"bugs", []string{"app-misc/a", "dev-libs/b", "app-misc/c"}},
+		{"maintainer", []string{"app-misc/a", "app-misc/c", "dev-libs/b"}},
+		{"unknown", []string{"app-misc/a", "app-misc/c", "dev-libs/b"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.key, func(t *testing.T) {
+			SortLeafPackages(leafs, tc.key)
+			got := make([]string, len(leafs))
+			for i, leaf := range leafs {
+				got[i] = leaf.Atom
+			}
+			if !slices.Equal(got, tc.expected) {
+				t.Errorf("Expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
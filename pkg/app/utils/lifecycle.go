@@ -0,0 +1,223 @@
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Contains utility functions to compute the lifecycle of versions
+
+package utils
+
+import (
+	"regexp"
+	"soko/pkg/database"
+	"soko/pkg/models"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// LifecycleEvent is a change of a version in the given commit
+type LifecycleEvent struct {
+	CommitId string
+	Date     time.Time
+}
+
+// VersionLifecycle describes when a version has been added, keyworded and
+// stabilized per arch, masked and removed. Events that didn't happen yet
+// are nil. Keyworded contains the first keywording of an arch, no matter
+// whether testing or stable.
+type VersionLifecycle struct {
+	VersionId  string
+	Version    string
+	Added      *LifecycleEvent
+	Keyworded  map[string]*LifecycleEvent
+	Stabilized map[string]*LifecycleEvent
+	Masked     *LifecycleEvent
+	Removed    *LifecycleEvent
+}
+
+// Days returns the number of days between the addition of the version
+// and the given event. false is returned in case either didn't happen.
+func (l *VersionLifecycle) Days(event *LifecycleEvent) (int, bool) {
+	if l.Added == nil || event == nil {
+		return 0, false
+	}
+	return int(event.Date.Sub(l.Added.Date).Hours() / 24), true
+}
+
+// Arches returns the arches of the given events sorted by name
+func (l *VersionLifecycle) Arches(events map[string]*LifecycleEvent) []string {
+	arches := make([]string, 0, len(events))
+	for arch := range events {
+		arches = append(arches, arch)
+	}
+	sort.Strings(arches)
+	return arches
+}
+
+// versionLink is a commit touching a version. Added and Deleted
+// report whether the ebuild has been added or deleted in the commit.
+type versionLink struct {
+	VersionId     string
+	CommitId      string
+	CommitterDate time.Time
+	Added         bool
+	Deleted       bool
+}
+
+// maskEvent is the first commit a version has been recorded as masked
+type maskEvent struct {
+	VersionId     string
+	CommitId      string
+	CommitterDate time.Time
+}
+
+// GetVersionLifecycles returns the lifecycles of all versions of the
+// package with the given atom, including versions that have been removed
+func GetVersionLifecycles(atom string) ([]*VersionLifecycle, error) {
+	category, name, _ := strings.Cut(atom, "/")
+
+	var links []versionLink
+	_, err := database.DBCon.Query(&links, `SELECT ctv.version_id, c.id AS commit_id, c.committer_date,
+			COALESCE(c.changed_files -> 'Added' @> jsonb_build_array(jsonb_build_object('Path', p.path))
+				OR c.changed_files -> 'Renamed' @> jsonb_build_array(jsonb_build_object('Path', p.path)), FALSE) AS added,
+			COALESCE(c.changed_files -> 'Deleted' @> jsonb_build_array(jsonb_build_object('Path', p.path))
+				OR c.changed_files -> 'Renamed' @> jsonb_build_array(jsonb_build_object('OldPath', p.path)), FALSE) AS deleted
+		FROM commit_to_versions AS ctv
+		JOIN commits AS c ON c.id = ctv.commit_id
+		CROSS JOIN LATERAL (SELECT ?0 || '/' || split_part(ctv.version_id, '/', 2) || '.ebuild' AS path) AS p
+		WHERE ctv.version_id LIKE ?1 AND ctv.version_id ~ ?2
+		ORDER BY c.preceding_commits`,
+		atom, category+"/"+name+"-%", "^"+regexp.QuoteMeta(category+"/"+name)+"-[0-9]")
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []*models.KeywordChange
+	err = database.DBCon.Model(&changes).
+		Relation("Commit").
+		Where("keyword_change.package_id = ?", atom).
+		Order("commit.preceding_commits").
+		Select()
+	if err != nil && err != pg.ErrNoRows {
+		return nil, err
+	}
+
+	var masked []maskEvent
+	_, err = database.DBCon.Query(&masked, `SELECT DISTINCT ON (h.version_id) h.version_id, c.id AS commit_id, c.committer_date
+		FROM version_histories AS h
+		JOIN commits AS c ON c.id = h.valid_from
+		WHERE h.atom = ? AND cardinality(h.masks) > 0
+		ORDER BY h.version_id, h.valid_from_index`, atom)
+	if err != nil {
+		return nil, err
+	}
+
+	var current []*models.Version
+	err = database.DBCon.Model(&current).
+		Column("id", "atom", "package", "version").
+		Relation("Masks").
+		Where("atom = ?", atom).
+		Select()
+	if err != nil && err != pg.ErrNoRows {
+		return nil, err
+	}
+
+	return buildLifecycles(atom, links, changes, masked, current), nil
+}
+
+// buildLifecycles combines the commits touching the versions, the keyword
+// changes and the mask events ordered by commit to the lifecycles of the
+// versions. The currently present versions are never reported as removed.
+func buildLifecycles(atom string, links []versionLink, changes []*models.KeywordChange, masked []maskEvent, current []*models.Version) []*VersionLifecycle {
+	category, name, _ := strings.Cut(atom, "/")
+	lifecycles := map[string]*VersionLifecycle{}
+	get := func(versionId string) *VersionLifecycle {
+		lifecycle, found := lifecycles[versionId]
+		if !found {
+			lifecycle = &VersionLifecycle{
+				VersionId:  versionId,
+				Version:    strings.TrimPrefix(versionId, category+"/"+name+"-"),
+				Keyworded:  map[string]*LifecycleEvent{},
+				Stabilized: map[string]*LifecycleEvent{},
+			}
+			lifecycles[versionId] = lifecycle
+		}
+		return lifecycle
+	}
+
+	present := map[string]bool{}
+	for _, version := range current {
+		present[version.Id] = true
+		get(version.Id)
+	}
+
+	firstLinks := map[string]*LifecycleEvent{}
+	for _, link := range links {
+		lifecycle := get(link.VersionId)
+		event := &LifecycleEvent{CommitId: link.CommitId, Date: link.CommitterDate}
+		if _, found := firstLinks[link.VersionId]; !found {
+			firstLinks[link.VersionId] = event
+		}
+		if link.Added && lifecycle.Added == nil {
+			lifecycle.Added = event
+			lifecycle.Removed = nil
+		}
+		if link.Deleted && !present[link.VersionId] {
+			lifecycle.Removed = event
+		}
+	}
+	for versionId, event := range firstLinks {
+		// e.g. versions of moved packages, which have been
+		// added using the path before the package move
+		if lifecycle := lifecycles[versionId]; lifecycle.Added == nil {
+			lifecycle.Added = event
+		}
+	}
+
+	for _, change := range changes {
+		if change.Commit == nil {
+			continue
+		}
+		lifecycle := get(change.VersionId)
+		event := &LifecycleEvent{CommitId: change.CommitId, Date: change.Commit.CommitterDate}
+		for _, keyword := range change.Added {
+			if strings.HasPrefix(keyword, "-") {
+				continue
+			}
+			arch := strings.TrimPrefix(keyword, "~")
+			setFirst(lifecycle.Keyworded, arch, event)
+			if arch == keyword {
+				setFirst(lifecycle.Stabilized, arch, event)
+			}
+		}
+		for _, arch := range change.Stabilized {
+			setFirst(lifecycle.Keyworded, arch, event)
+			setFirst(lifecycle.Stabilized, arch, event)
+		}
+	}
+
+	for _, mask := range masked {
+		get(mask.VersionId).Masked = &LifecycleEvent{CommitId: mask.CommitId, Date: mask.CommitterDate}
+	}
+	for _, version := range current {
+		// masks that predate the recorded history
+		if lifecycle := lifecycles[version.Id]; lifecycle.Masked == nil && len(version.Masks) > 0 {
+			lifecycle.Masked = &LifecycleEvent{Date: version.Masks[0].Date}
+		}
+	}
+
+	result := make([]*VersionLifecycle, 0, len(lifecycles))
+	for _, lifecycle := range lifecycles {
+		result = append(result, lifecycle)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return (&models.Version{Version: result[i].Version}).GreaterThan(models.Version{Version: result[j].Version})
+	})
+	return result
+}
+
+func setFirst(events map[string]*LifecycleEvent, arch string, event *LifecycleEvent) {
+	if _, found := events[arch]; !found {
+		events[arch] = event
+	}
+}
@@ -0,0 +1,61 @@
+// SPDX-License-Identifier: GPL-2.0-only
+package utils
+
+import (
+	"soko/pkg/models"
+	"testing"
+	"time"
+)
+
+func TestBuildLifecycles(t *testing.T) {
+	day := func(n int) time.Time {
+		return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, n)
+	}
+	links := []versionLink{
+		{VersionId: "dev-lang/foo-1.0", CommitId: "a", CommitterDate: day(0), Added: true},
+		{VersionId: "dev-lang/foo-1.0", CommitId: "b", CommitterDate: day(10)},
+		{VersionId: "dev-lang/foo-2.0", CommitId: "c", CommitterDate: day(20)},
+		{VersionId: "dev-lang/foo-1.0", CommitId: "d", CommitterDate: day(40), Deleted: true},
+	}
+	changes := []*models.KeywordChange{
+		{CommitId: "a", VersionId: "dev-lang/foo-1.0", Commit: &models.Commit{CommitterDate: day(0)}, Added: []string{"~amd64", "arm64", "-*"}},
+		{CommitId: "b", VersionId: "dev-lang/foo-1.0", Commit: &models.Commit{CommitterDate: day(10)}, Stabilized: []string{"amd64"}},
+	}
+	masked := []maskEvent{{VersionId: "dev-lang/foo-1.0", CommitId: "c", CommitterDate: day(20)}}
+	current := []*models.Version{{Id: "dev-lang/foo-2.0", Atom: "dev-lang/foo", Version: "2.0"}}
+
+	lifecycles := buildLifecycles("dev-lang/foo", links, changes, masked, current)
+	if len(lifecycles) != 2 {
+		t.Fatalf("Expected 2 lifecycles, got %d", len(lifecycles))
+	}
+
+	newer, older := lifecycles[0], lifecycles[1]
+	if newer.Version != "2.0" || older.Version != "1.0" {
+		t.Fatalf("Unexpected order %s, %s", newer.Version, older.Version)
+	}
+	if newer.Added == nil || newer.Added.CommitId != "c" {
+		t.Errorf("Expected 2.0 to be added by its first commit, got %v", newer.Added)
+	}
+	if newer.Removed != nil {
+		t.Errorf("Expected present version not to be removed")
+	}
+
+	if days, ok := older.Days(older.Stabilized["amd64"]); !ok || days != 10 {
+		t.Errorf("Expected amd64 stable after 10 days, got %d", days)
+	}
+	if days, ok := older.Days(older.Stabilized["arm64"]); !ok || days != 0 {
+		t.Errorf("Expected arm64 stable when added, got %d", days)
+	}
+	if _, found := older.Keyworded["*"]; found {
+		t.Errorf("Expected -* to be ignored")
+	}
+	if older.Keyworded["amd64"].CommitId != "a" {
+		t.Errorf("Expected amd64 to be keyworded first in commit a")
+	}
+	if older.Masked == nil || older.Masked.CommitId != "c" {
+		t.Errorf("Expected 1.0 to be masked in commit c")
+	}
+	if older.Removed == nil || older.Removed.CommitId != "d" {
+		t.Errorf("Expected 1.0 to be removed in commit d")
+	}
+}
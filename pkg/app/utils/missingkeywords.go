@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Contains utility functions to find dependencies lacking keywords
+
+package utils
+
+import (
+	"soko/pkg/database"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// MissingKeyword is a dependency of a version that isn't keyworded on
+// an arch the version is keyworded on. In case the version is stable
+// on the arch, no version of the dependency is stable on the arch.
+// Unavailable dependencies don't have any version in the tree.
+type MissingKeyword struct {
+	Arch        string
+	VersionId   string
+	Atom        string
+	Dependency  string
+	Type        string
+	Condition   string
+	Stable      bool
+	Unavailable bool
+}
+
+// Keyword returns the keyword the dependency is lacking
+func (m *MissingKeyword) Keyword() string {
+	if m.Stable {
+		return m.Arch
+	}
+	return "~" + m.Arch
+}
+
+// TypeName returns the name of the dependency variable
+func (m *MissingKeyword) TypeName() string {
+	switch m.Type {
+	case "rindex":
+		return "RDEPEND"
+	case "dindex":
+		return "DEPEND"
+	}
+	return m.Type
+}
+
+// GetMissingKeywords returns the RDEPEND and DEPEND dependencies lacking
+// keywords on the given arches. In case atom isn't empty, only the
+// versions of the package with the given atom are checked.
+func GetMissingKeywords(arches []string, atom string) ([]*MissingKeyword, error) {
+	var missing []*MissingKeyword
+	_, err := database.DBCon.Query(&missing, `WITH keyword AS (
+			SELECT v.id, v.atom, a.arch,
+				a.arch = ANY(STRING_TO_ARRAY(v.keywords, ' ')) AS stable,
+				'~' || a.arch = ANY(STRING_TO_ARRAY(v.keywords, ' ')) AS testing
+			FROM versions AS v
+			CROSS JOIN UNNEST(?0::TEXT[]) AS a(arch)
+			WHERE ?1 = '' OR v.atom = ?1 OR v.atom IN (
+				SELECT rd.atom FROM reverse_dependencies AS rd WHERE rd.reverse_dependency_atom = ?1)
+		), package_keyword AS (
+			SELECT atom, arch, BOOL_OR(stable) AS stable, BOOL_OR(stable OR testing) AS keyworded
+			FROM keyword
+			GROUP BY atom, arch
+		)
+		SELECT DISTINCT k.arch, k.id AS version_id, k.atom, rd.atom AS dependency, rd.type, rd.condition, k.stable,
+			pk.atom IS NULL AS unavailable
+		FROM keyword AS k
+		JOIN reverse_dependencies AS rd ON rd.reverse_dependency_version = k.id
+		LEFT JOIN package_keyword AS pk ON pk.atom = rd.atom AND pk.arch = k.arch
+		WHERE (k.stable OR k.testing)
+			AND (?1 = '' OR k.atom = ?1)
+			AND rd.type IN ('rindex', 'dindex')
+			AND rd.atom <> k.atom
+			AND NOT COALESCE(CASE WHEN k.stable THEN pk.stable ELSE pk.keyworded END, FALSE)
+		ORDER BY k.arch, k.atom, k.id, rd.atom, rd.type, rd.condition`, pg.Array(arches), atom)
+	if err != nil {
+		return nil, err
+	}
+	return mergeConditions(missing), nil
+}
+
+// mergeConditions merges the consecutive entries that only differ in
+// the condition of the dependency into one entry listing all conditions.
+// An unconditional dependency makes the merged entry unconditional.
+func mergeConditions(missing []*MissingKeyword) []*MissingKeyword {
+	var merged []*MissingKeyword
+	for _, entry := range missing {
+		if len(merged) > 0 {
+			last := merged[len(merged)-1]
+			if last.Arch == entry.Arch && last.VersionId == entry.VersionId &&
+				last.Dependency == entry.Dependency && last.Type == entry.Type {
+				if last.Condition != "" && entry.Condition != "" {
+					last.Condition += ", " + entry.Condition
+				} else {
+					last.Condition = ""
+				}
+				continue
+			}
+		}
+		merged = append(merged, entry)
+	}
+	return merged
+}
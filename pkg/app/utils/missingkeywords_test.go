@@ -0,0 +1,35 @@
+// SPDX-License-Identifier: GPL-2.0-only
+package utils
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMergeConditions(t *testing.T) {
+	entry := func(version, dependency, condition string) *MissingKeyword {
+		return &MissingKeyword{Arch: "amd64", VersionId: version, Dependency: dependency, Type: "rindex", Condition: condition}
+	}
+	testCases := []struct {
+		name     string
+		missing  []*MissingKeyword
+		expected []string
+	}{
+		{"empty", nil, nil},
+		{"conditions", []*MissingKeyword{entry("a/b-1", "c/d", "gui"), entry("a/b-1", "c/d", "test")}, []string{"a/b-1 c/d gui, test"}},
+		{"unconditional", []*MissingKeyword{entry("a/b-1", "c/d", ""), entry("a/b-1", "c/d", "gui")}, []string{"a/b-1 c/d "}},
+		{"other dependency", []*MissingKeyword{entry("a/b-1", "c/d", "gui"), entry("a/b-1", "c/e", "gui")}, []string{"a/b-1 c/d gui", "a/b-1 c/e gui"}},
+		{"other version", []*MissingKeyword{entry("a/b-1", "c/d", ""), entry("a/b-2", "c/d", "")}, []string{"a/b-1 c/d ", "a/b-2 c/d "}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			for _, entry := range mergeConditions(tc.missing) {
+				got = append(got, entry.VersionId+" "+entry.Dependency+" "+entry.Condition)
+			}
+			if !slices.Equal(got, tc.expected) {
+				t.Errorf("Expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
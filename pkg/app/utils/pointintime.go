@@ -0,0 +1,177 @@
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Contains utility functions to show the tree at a given commit
+
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"soko/pkg/database"
+	"soko/pkg/models"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+var commitHash = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
+// ParseAt resolves the "at" parameter of the request to the commit the
+// tree should be shown at. The parameter is either a (shortened) commit
+// hash or a date, in which case the last commit before the end of the
+// date is used. nil is returned in case the parameter is not given. In
+// case the history has not been recorded at the commit, a NoHistoryError
+// is returned.
+func ParseAt(r *http.Request) (*models.Commit, error) {
+	at := r.URL.Query().Get("at")
+	if at == "" {
+		return nil, nil
+	}
+	hash, until, err := parseAtParameter(at)
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []*models.Commit
+	query := database.DBCon.Model(&commits).
+		Column("id", "preceding_commits", "committer_date").
+		Order("preceding_commits DESC")
+	if hash != "" {
+		query = query.Where("id LIKE ?", hash+"%").Limit(2)
+	} else {
+		query = query.Where("committer_date <= ?", until).Limit(1)
+	}
+	if err := query.Select(); err != nil {
+		return nil, err
+	}
+	if len(commits) == 0 {
+		return nil, errors.New("unknown commit or date: " + at)
+	} else if len(commits) > 1 {
+		return nil, errors.New("ambiguous commit: " + at)
+	}
+	commit := commits[0]
+
+	// the history is recorded starting with the first update after
+	// the deployment, so that older trees can't be shown
+	first := new(models.Commit)
+	err = database.DBCon.Model(first).
+		Column("id", "preceding_commits", "committer_date").
+		Where("preceding_commits = (SELECT MIN(valid_from_index) FROM version_histories)").
+		Select()
+	if err == pg.ErrNoRows {
+		return nil, &NoHistoryError{}
+	} else if err != nil {
+		return nil, err
+	}
+	if commit.PrecedingCommits < first.PrecedingCommits {
+		return nil, &NoHistoryError{First: first}
+	}
+	return commit, nil
+}
+
+// parseAtParameter parses the "at" parameter, which is either a commit
+// hash prefix, a date or a point in time in RFC 3339 format. Either the
+// hash or the point in time the last commit is searched up to is returned.
+// As commit dates have a precision of seconds, the end of a date is its
+// last second.
+func parseAtParameter(at string) (string, time.Time, error) {
+	if commitHash.MatchString(at) {
+		// e.g. 20240101 is both, a hash prefix and a date
+		if _, err := time.Parse("20060102", at); err == nil {
+			return "", time.Time{}, errors.New("ambiguous commit or date, use YYYY-MM-DD for dates: " + at)
+		}
+		return at, time.Time{}, nil
+	} else if date, err := time.Parse(time.DateOnly, at); err == nil {
+		return "", date.AddDate(0, 0, 1).Add(-time.Second), nil
+	} else if date, err := time.Parse(time.RFC3339, at); err == nil {
+		return "", date, nil
+	}
+	return "", time.Time{}, errors.New("invalid commit or date: " + at)
+}
+
+// NoHistoryError is returned by ParseAt for commits before the first
+// commit the history has been recorded at
+type NoHistoryError struct {
+	First *models.Commit
+}
+
+func (e *NoHistoryError) Error() string {
+	if e.First == nil {
+		return "no history has been recorded yet"
+	}
+	return "no history is recorded before commit " + e.First.Id[:7] +
+		" (" + e.First.CommitterDate.UTC().Format(time.DateTime) + " UTC)"
+}
+
+// AtError responds with the error returned by ParseAt
+func AtError(w http.ResponseWriter, err error) {
+	var noHistory *NoHistoryError
+	if errors.As(err, &noHistory) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadRequest)
+}
+
+// AtQuery returns the query string to keep
+// showing the tree at the given commit in links
+func AtQuery(commit *models.Commit) string {
+	if commit == nil {
+		return ""
+	}
+	return "?at=" + commit.Id
+}
+
+// ValidAt restricts the query of a history table to the rows
+// that have been valid at the given commit
+func ValidAt(q *orm.Query, commit *models.Commit) *orm.Query {
+	return q.Where("valid_from_index <= ?", commit.PrecedingCommits).
+		WhereGroup(func(q *orm.Query) (*orm.Query, error) {
+			return q.Where("valid_to_index IS NULL").
+				WhereOr("valid_to_index > ?", commit.PrecedingCommits), nil
+		})
+}
+
+// VersionsAt returns the versions of the package with the given atom as
+// they have been at the given commit, including the masks at that time
+func VersionsAt(atom string, commit *models.Commit) ([]*models.Version, error) {
+	var rows []*models.VersionHistory
+	err := ValidAt(database.DBCon.Model(&rows), commit).
+		Where("atom = ?", atom).
+		Order("version DESC").
+		Select()
+	if err != nil {
+		return nil, err
+	}
+
+	var specifiers []string
+	for _, row := range rows {
+		specifiers = append(specifiers, row.Masks...)
+	}
+	masks := map[string]*models.Mask{}
+	if len(specifiers) > 0 {
+		var maskRows []*models.MaskHistory
+		err = ValidAt(database.DBCon.Model(&maskRows), commit).
+			WhereIn("versions IN (?)", specifiers).
+			Select()
+		if err != nil {
+			return nil, err
+		}
+		for _, mask := range maskRows {
+			masks[mask.Versions] = mask.ToMask()
+		}
+	}
+
+	versions := make([]*models.Version, len(rows))
+	for i, row := range rows {
+		versions[i] = row.ToVersion()
+		for _, specifier := range row.Masks {
+			if mask, found := masks[specifier]; found {
+				versions[i].Masks = append(versions[i].Masks, mask)
+			}
+		}
+	}
+	return versions, nil
+}
@@ -0,0 +1,34 @@
+// SPDX-License-Identifier: GPL-2.0-only
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAtParameter(t *testing.T) {
+	testCases := []struct {
+		at    string
+		hash  string
+		until time.Time
+		valid bool
+	}{
+		{"abc1234", "abc1234", time.Time{}, true},
+		{"0123456789abcdef0123456789abcdef01234567", "0123456789abcdef0123456789abcdef01234567", time.Time{}, true},
+		{"12345678", "12345678", time.Time{}, true},
+		{"2024-01-31", "", time.Date(2024, 1, 31, 23, 59, 59, 0, time.UTC), true},
+		{"2024-01-31T12:00:00+01:00", "", time.Date(2024, 1, 31, 11, 0, 0, 0, time.UTC), true},
+		{"20240101", "", time.Time{}, false},
+		{"abc", "", time.Time{}, false},
+		{"ABC1234", "", time.Time{}, false},
+		{"yesterday", "", time.Time{}, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.at, func(t *testing.T) {
+			hash, until, err := parseAtParameter(tc.at)
+			if (err == nil) != tc.valid || hash != tc.hash || !until.Equal(tc.until) {
+				t.Errorf("Expected %q, %v, %t, got %q, %v, %v", tc.hash, tc.until, tc.valid, hash, until, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Contains utility functions to compute stabilization statistics
+
+package utils
+
+import (
+	"math"
+	"soko/pkg/database"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// StatisticsWindow is the period of stabilizations the statistics are computed of
+const StatisticsWindow = 365 * 24 * time.Hour
+
+// EligibilityDelay is the time a version has to be in the tree before
+// pkgcheck reports a StableRequest for it. It is only used to estimate
+// the eligibility of versions stabilized before the first StableRequest
+// has been recorded for them.
+const EligibilityDelay = 30 * 24 * time.Hour
+
+// DurationStatistics summarizes durations in days
+type DurationStatistics struct {
+	Count      int     `json:"count"`
+	MedianDays float64 `json:"median_days"`
+	P95Days    float64 `json:"p95_days"`
+}
+
+// StabilizationStatistics describes how long the stabilizations of an
+// arch took. FromEligibility is measured from the point in time the
+// version became eligible for stabilization, that is the StableRequest
+// appeared, and FromBug from the filing of the stabilization bug.
+// EstimatedEligibility is the number of samples of FromEligibility
+// without a recorded StableRequest, whose eligibility is estimated
+// using the EligibilityDelay.
+type StabilizationStatistics struct {
+	Arch                 string             `json:"arch"`
+	Stabilizations       int                `json:"stabilizations"`
+	FromEligibility      DurationStatistics `json:"from_eligibility"`
+	EstimatedEligibility int                `json:"estimated_eligibility"`
+	FromBug              DurationStatistics `json:"from_bug"`
+}
+
+// stabilizationSample is the stabilization of a version on an arch
+type stabilizationSample struct {
+	Arch         string
+	VersionId    string
+	StabilizedAt time.Time
+	AddedAt      time.Time
+	EligibleAt   time.Time
+	RequestedAt  time.Time
+}
+
+// GetStabilizationStatistics computes the statistics of the stabilizations
+// within the StatisticsWindow per arch. The statistics are restricted to
+// the given arch, unless it is empty, and to the packages selected by the
+// given query of atoms, unless it is nil.
+func GetStabilizationStatistics(arch string, packages *pg.Query) ([]*StabilizationStatistics, error) {
+	query := `SELECT s.arch, kc.version_id, c.committer_date AS stabilized_at,
+			(SELECT MIN(c2.committer_date) FROM commit_to_versions AS ctv
+				JOIN commits AS c2 ON c2.id = ctv.commit_id
+				WHERE ctv.version_id = kc.version_id) AS added_at,
+			(SELECT sr.first_seen FROM stable_requests AS sr
+				WHERE sr.version_id = kc.version_id) AS eligible_at,
+			(SELECT MIN(sb.creation_time) FROM stabilization_bugs AS sb
+				WHERE sb.versions @> jsonb_build_array(kc.version_id)
+				AND sb.creation_time <= c.committer_date) AS requested_at
+		FROM keyword_changes AS kc
+		JOIN commits AS c ON c.id = kc.commit_id
+		CROSS JOIN LATERAL jsonb_array_elements_text(kc.stabilized) AS s(arch)
+		WHERE c.committer_date >= ?`
+	params := []interface{}{time.Now().Add(-StatisticsWindow)}
+	if arch != "" {
+		query += ` AND s.arch = ?`
+		params = append(params, arch)
+	}
+	if packages != nil {
+		query += ` AND kc.package_id IN (?)`
+		params = append(params, packages)
+	}
+
+	var samples []stabilizationSample
+	if _, err := database.DBCon.Query(&samples, query, params...); err != nil {
+		return nil, err
+	}
+	return summarizeStabilizations(samples), nil
+}
+
+// summarizeStabilizations computes the statistics of the samples per arch
+func summarizeStabilizations(samples []stabilizationSample) []*StabilizationStatistics {
+	fromEligibility := map[string][]float64{}
+	fromBug := map[string][]float64{}
+	counts := map[string]int{}
+	estimated := map[string]int{}
+	for _, sample := range samples {
+		counts[sample.Arch]++
+		eligibleAt := sample.EligibleAt
+		if eligibleAt.IsZero() && !sample.AddedAt.IsZero() {
+			eligibleAt = sample.AddedAt.Add(EligibilityDelay)
+			estimated[sample.Arch]++
+		}
+		if !eligibleAt.IsZero() {
+			// e.g. security fixes are stabilized before being eligible
+			fromEligibility[sample.Arch] = append(fromEligibility[sample.Arch], max(days(sample.StabilizedAt.Sub(eligibleAt)), 0))
+		}
+		if !sample.RequestedAt.IsZero() {
+			fromBug[sample.Arch] = append(fromBug[sample.Arch], days(sample.StabilizedAt.Sub(sample.RequestedAt)))
+		}
+	}
+
+	statistics := make([]*StabilizationStatistics, 0, len(counts))
+	for arch, count := range counts {
+		statistics = append(statistics, &StabilizationStatistics{
+			Arch:                 arch,
+			Stabilizations:       count,
+			FromEligibility:      durationStatistics(fromEligibility[arch]),
+			EstimatedEligibility: estimated[arch],
+			FromBug:              durationStatistics(fromBug[arch]),
+		})
+	}
+	sort.Slice(statistics, func(i, j int) bool {
+		return strings.Compare(statistics[i].Arch, statistics[j].Arch) < 0
+	})
+	return statistics
+}
+
+// durationStatistics returns the median and 95th percentile of the
+// given days using the nearest-rank method
+func durationStatistics(values []float64) DurationStatistics {
+	if len(values) == 0 {
+		return DurationStatistics{}
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	percentile := func(p float64) float64 {
+		rank := int(math.Ceil(p * float64(len(sorted))))
+		return sorted[max(rank, 1)-1]
+	}
+	return DurationStatistics{
+		Count:      len(sorted),
+		MedianDays: percentile(0.5),
+		P95Days:    percentile(0.95),
+	}
+}
+
+func days(d time.Duration) float64 {
+	return math.Round(d.Hours()/24*10) / 10
+}
@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: GPL-2.0-only
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDurationStatistics(t *testing.T) {
+	testCases := []struct {
+		name     string
+		values   []float64
+		expected DurationStatistics
+	}{
+		{"empty", nil, DurationStatistics{}},
+		{"single", []float64{3}, DurationStatistics{1, 3, 3}},
+		{"unsorted", []float64{5, 1, 3, 2, 4}, DurationStatistics{5, 3, 5}},
+		{"even", []float64{1, 2, 3, 4}, DurationStatistics{4, 2, 4}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := durationStatistics(tc.values); got != tc.expected {
+				t.Errorf("Expected %+v, got %+v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestSummarizeStabilizations(t *testing.T) {
+	added := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	samples := []stabilizationSample{
+		{Arch: "amd64", StabilizedAt: added.AddDate(0, 0, 40), AddedAt: added, RequestedAt: added.AddDate(0, 0, 35)},
+		{Arch: "amd64", StabilizedAt: added.AddDate(0, 0, 5), AddedAt: added},
+		{Arch: "amd64", StabilizedAt: added.AddDate(0, 0, 50), AddedAt: added, EligibleAt: added.AddDate(0, 0, 48)},
+		{Arch: "arm64", StabilizedAt: added.AddDate(0, 0, 60)},
+	}
+	statistics := summarizeStabilizations(samples)
+	if len(statistics) != 2 || statistics[0].Arch != "amd64" || statistics[1].Arch != "arm64" {
+		t.Fatalf("Unexpected statistics %+v", statistics)
+	}
+
+	amd64 := statistics[0]
+	if amd64.Stabilizations != 3 {
+		t.Errorf("Expected 3 stabilizations, got %d", amd64.Stabilizations)
+	}
+	if expected := (DurationStatistics{3, 2, 10}); amd64.FromEligibility != expected {
+		t.Errorf("Expected %+v, got %+v", expected, amd64.FromEligibility)
+	}
+	if amd64.EstimatedEligibility != 2 {
+		t.Errorf("Expected 2 estimated eligibilities, got %d", amd64.EstimatedEligibility)
+	}
+	if expected := (DurationStatistics{1, 5, 5}); amd64.FromBug != expected {
+		t.Errorf("Expected %+v, got %+v", expected, amd64.FromBug)
+	}
+	if statistics[1].FromEligibility.Count != 0 {
+		t.Errorf("Expected no eligibility statistics without addition")
+	}
+}
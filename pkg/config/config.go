@@ -4,6 +4,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -11,6 +12,66 @@ func PortDir() string {
 	return getEnv("SOKO_PORT_DIR", "/mnt/packages-tree/gentoo")
 }
 
+// GitSync reports whether soko clones and fetches the repository in
+// PortDir and regenerates its md5-cache itself before updating, which
+// requires pmaint. By default this is done by bin/update.sh instead.
+func GitSync() bool {
+	return getEnv("SOKO_GIT_SYNC", "false") == "true"
+}
+
+func GitRemote() string {
+	return getEnv("SOKO_GIT_REMOTE", "https://anongit.gentoo.org/git/repo/gentoo.git")
+}
+
+func GitBranch() string {
+	return getEnv("SOKO_GIT_BRANCH", "master")
+}
+
+// GitKeyring is the path of the OpenPGP keyring containing
+// the keys that are trusted to sign the head of the branch
+func GitKeyring() string {
+	return getEnv("SOKO_GIT_KEYRING", "/usr/share/openpgp-keys/gentoo-developers.asc")
+}
+
+// GitVerify reports whether the signature of the head fetched by GitSync
+// is verified, which requires gpg and the keyring at GitKeyring, e.g.
+// as installed by sec-keys/openpgp-keys-gentoo-developers. Verification
+// is enabled whenever GitSync is, unless SOKO_GIT_VERIFY is set to false
+// explicitly, e.g. for a remote without signed commits.
+func GitVerify() bool {
+	return GitSync() && getEnv("SOKO_GIT_VERIFY", "true") != "false"
+}
+
+// FilesDir is the directory containing the CONTENTS of installed packages
+// in the layout of /var/db/pkg, that is <category>/<name>-<version>/CONTENTS.
+// In case it is empty, no files are imported.
+func FilesDir() string {
+	return getEnv("SOKO_FILES_DIR", "")
+}
+
+// Binhosts are the Packages indices of the binhosts, whose binary packages
+// are imported, separated by whitespace. Each index is either a URL or a
+// path to a local file. In case it is empty, no binary packages are imported,
+// e.g. https://distfiles.gentoo.org/releases/amd64/binpackages/23.0/x86-64/Packages
+// imports the binary packages of the amd64 binhost.
+func Binhosts() []string {
+	return strings.Fields(getEnv("SOKO_BINHOSTS", ""))
+}
+
+// DumpsDir is the directory the database dumps are written to by the
+// updater and served from by the web server, so that it has to be shared
+// between both, e.g. using a volume mounted into both containers
+func DumpsDir() string {
+	return getEnv("SOKO_DUMPS_DIR", "/mnt/dumps")
+}
+
+// DevelopersFile is the export of the developer directory, either as
+// userinfo XML, JSON or LDIF. It is either a URL or a path to a local
+// file. In case it is empty, no developers are imported.
+func DevelopersFile() string {
+	return getEnv("SOKO_DEVELOPERS_FILE", "")
+}
+
 func PostgresUser() string {
 	return getEnv("SOKO_POSTGRES_USER", "root")
 }
@@ -65,6 +126,42 @@ func CacheControl() string {
 
 const CacheTime = 5 * time.Minute
 
+// WebhookSecret is the secret used to verify the signature of push
+// webhooks. In case it is empty, the webhook endpoint is disabled.
+// Pushes are recorded as pending updates, which are run by the
+// scheduler daemon (--daemon) in the updater environment, so that
+// the daemon has to fetch the repository itself, see GitSync.
+func WebhookSecret() string {
+	return getEnv("SOKO_WEBHOOK_SECRET", "")
+}
+
+// WebhookRef is the ref whose pushes trigger an update
+func WebhookRef() string {
+	return getEnv("SOKO_WEBHOOK_REF", "refs/heads/"+GitBranch())
+}
+
+// WebhookDebounce is the time the scheduler daemon waits for
+// further pushes before running an update requested by a webhook
+func WebhookDebounce() time.Duration {
+	debounce, err := time.ParseDuration(getEnv("SOKO_WEBHOOK_DEBOUNCE", "10s"))
+	if err != nil {
+		return 10 * time.Second
+	}
+	return debounce
+}
+
+// ScheduleInterval returns the interval the job with the given name is run
+// with by the scheduler, configured using SOKO_SCHEDULE_<NAME>, e.g.
+// SOKO_SCHEDULE_UPDATE_BUGS=30m. An interval of 0 disables the job.
+func ScheduleInterval(job string, fallback time.Duration) time.Duration {
+	key := "SOKO_SCHEDULE_" + strings.ToUpper(strings.ReplaceAll(job, "-", "_"))
+	interval, err := time.ParseDuration(getEnv(key, fallback.String()))
+	if err != nil {
+		return fallback
+	}
+	return interval
+}
+
 func UserAgent() string {
 	return fmt.Sprintf("Gentoo Soko %s/packages.gentoo.org/gpackages@gentoo.org", Version())
 }
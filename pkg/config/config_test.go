@@ -0,0 +1,27 @@
+// SPDX-License-Identifier: GPL-2.0-only
+package config
+
+import "testing"
+
+func TestGitVerify(t *testing.T) {
+	testCases := []struct {
+		name     string
+		sync     string
+		verify   string
+		expected bool
+	}{
+		{"sync disabled", "false", "", false},
+		{"sync enabled", "true", "", true},
+		{"explicitly disabled", "true", "false", false},
+		{"explicitly enabled", "true", "true", true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("SOKO_GIT_SYNC", tc.sync)
+			t.Setenv("SOKO_GIT_VERIFY", tc.verify)
+			if got := GitVerify(); got != tc.expected {
+				t.Errorf("Expected %t, got %t", tc.expected, got)
+			}
+		})
+	}
+}
@@ -35,7 +35,11 @@ func CreateSchema() error {
 		(*models.MaskToVersion)(nil),
 		(*models.DeprecatedToVersion)(nil),
 		(*models.Package)(nil),
+		(*models.PackageSearch)(nil),
+		(*models.PackageFile)(nil),
+		(*models.BinaryPackage)(nil),
 		(*models.PkgMove)(nil),
+		(*models.Arch)(nil),
 		(*models.CategoryPackagesInformation)(nil),
 		(*models.Category)(nil),
 		(*models.Version)(nil),
@@ -48,11 +52,20 @@ func CreateSchema() error {
 		(*models.Project)(nil),
 		(*models.MaintainerToProject)(nil),
 		(*models.PkgCheckResult)(nil),
+		(*models.StableRequest)(nil),
 		(*models.PullRequest)(nil),
 		(*models.Bug)(nil),
+		(*models.StabilizationBug)(nil),
 		(*models.ReverseDependency)(nil),
 		(*models.Maintainer)(nil),
+		(*models.Developer)(nil),
 		(*models.Application)(nil),
+		(*models.UpdateRun)(nil),
+		(*models.ScheduledJob)(nil),
+		(*models.PendingUpdate)(nil),
+		(*models.RepositoryHead)(nil),
+		(*models.VersionHistory)(nil),
+		(*models.MaskHistory)(nil),
 	} {
 		err := DBCon.Model(model).CreateTable(&orm.CreateTableOptions{
 			IfNotExists: true,
@@ -63,11 +76,50 @@ func CreateSchema() error {
 			return err
 		}
 	}
+	// CreateTable doesn't touch existing tables, so that
+	// columns added to existing models have to be added here
+	for _, column := range []string{
+		"ALTER TABLE keyword_changes ADD COLUMN IF NOT EXISTS destabilized jsonb",
+		"ALTER TABLE keyword_changes ADD COLUMN IF NOT EXISTS dropped jsonb",
+		"ALTER TABLE projects ADD COLUMN IF NOT EXISTS subprojects jsonb",
+		"ALTER TABLE projects ADD COLUMN IF NOT EXISTS inherited_members jsonb",
+		"ALTER TABLE pkg_check_results ADD COLUMN IF NOT EXISTS level text",
+		"ALTER TABLE scheduled_jobs ADD COLUMN IF NOT EXISTS triggered_by jsonb",
+	} {
+		_, err := DBCon.Exec(column)
+		if err != nil {
+			slog.Error("Failed adding column", slog.String("column", column), slog.Any("err", err))
+			return err
+		}
+	}
 	_, err := DBCon.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm")
 	if err != nil {
 		slog.Error("Failed creating extension 'pg_trgm'", slog.Any("err", err))
 		return err
 	}
+	_, err = DBCon.Exec("CREATE INDEX IF NOT EXISTS package_searches_document_idx ON package_searches USING GIN (document)")
+	if err != nil {
+		slog.Error("Failed creating full text search index", slog.Any("err", err))
+		return err
+	}
+	for _, index := range []string{
+		"CREATE INDEX IF NOT EXISTS package_files_path_idx ON package_files (path)",
+		"CREATE INDEX IF NOT EXISTS package_files_name_idx ON package_files (name)",
+		"CREATE INDEX IF NOT EXISTS binary_packages_version_id_idx ON binary_packages (version_id)",
+		"CREATE INDEX IF NOT EXISTS binary_packages_atom_idx ON binary_packages (atom)",
+		"CREATE INDEX IF NOT EXISTS version_histories_atom_idx ON version_histories (atom)",
+		"CREATE INDEX IF NOT EXISTS version_histories_category_idx ON version_histories (category)",
+		"CREATE INDEX IF NOT EXISTS version_histories_valid_idx ON version_histories (valid_from_index, valid_to_index)",
+		"CREATE INDEX IF NOT EXISTS version_histories_open_idx ON version_histories (version_id) WHERE valid_to IS NULL",
+		"CREATE INDEX IF NOT EXISTS mask_histories_versions_idx ON mask_histories (versions)",
+		"CREATE INDEX IF NOT EXISTS mask_histories_valid_idx ON mask_histories (valid_from_index, valid_to_index)",
+	} {
+		_, err = DBCon.Exec(index)
+		if err != nil {
+			slog.Error("Failed creating index", slog.String("index", index), slog.Any("err", err))
+			return err
+		}
+	}
 
 	return nil
 }
@@ -90,13 +142,7 @@ func (d dbLogger) AfterQuery(c context.Context, q *pg.QueryEvent) error {
 // Connect is used to connect to the database
 // and turn on logging if desired
 func Connect() {
-	DBCon = pg.Connect(&pg.Options{
-		User:        config.PostgresUser(),
-		Password:    config.PostgresPass(),
-		Database:    config.PostgresDb(),
-		Addr:        config.PostgresHost() + ":" + config.PostgresPort(),
-		DialTimeout: 10 * time.Second,
-	})
+	DBCon = Open()
 
 	if !config.Quiet() {
 		DBCon.AddQueryHook(dbLogger{})
@@ -108,6 +154,18 @@ func Connect() {
 	}
 }
 
+// Open opens a new connection pool to the database, which
+// is independent of the connection handle DBCon
+func Open() *pg.DB {
+	return pg.Connect(&pg.Options{
+		User:        config.PostgresUser(),
+		Password:    config.PostgresPass(),
+		Database:    config.PostgresDb(),
+		Addr:        config.PostgresHost() + ":" + config.PostgresPort(),
+		DialTimeout: 10 * time.Second,
+	})
+}
+
 func TruncateTable(model any) {
 	query := DBCon.Model(model)
 	tableName := string(query.TableModel().Table().TypeName)
@@ -118,3 +176,10 @@ func TruncateTable(model any) {
 		slog.Info("Truncated table", slog.String("table", tableName))
 	}
 }
+
+// Truncate truncates the table of the given model using the
+// given handle, so that it can be part of a transaction
+func Truncate(db orm.DB, model any) error {
+	_, err := db.Model(model).Exec("TRUNCATE TABLE ?TableName")
+	return err
+}
@@ -0,0 +1,34 @@
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Contains utility functions around PostgreSQL advisory locks
+
+package database
+
+import (
+	"log/slog"
+)
+
+// UpdateLockKey is the key of the advisory lock held during
+// an update, so that updates of several processes don't overlap
+const UpdateLockKey = 0x736f6b6f01
+
+// WithLock runs fn while holding the advisory lock with the given key.
+// In case another session is holding the lock, WithLock waits until
+// the lock has been released. The error of fn is returned.
+func WithLock(key int64, fn func() error) error {
+	// the advisory lock belongs to the session, so that
+	// the same connection has to be used for unlocking
+	conn := DBCon.Conn()
+	defer conn.Close()
+
+	if _, err := conn.Exec("SELECT pg_advisory_lock(?)", key); err != nil {
+		return err
+	}
+	defer func() {
+		if _, err := conn.Exec("SELECT pg_advisory_unlock(?)", key); err != nil {
+			slog.Error("Failed releasing advisory lock", slog.Int64("key", key), slog.Any("err", err))
+		}
+	}()
+
+	return fn()
+}
@@ -0,0 +1,32 @@
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Contains utility functions to run the importers in transactions
+
+package database
+
+import (
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// InTransaction runs the given function in a transaction. The function
+// has to do all its queries using the given handle, so that they are part
+// of the transaction. The transaction is committed if the function returns
+// nil and rolled back if the function returns an error or panics.
+func InTransaction(fn func(tx orm.DB) error) error {
+	return DBCon.RunInTransaction(DBCon.Context(), func(tx *pg.Tx) error {
+		return fn(tx)
+	})
+}
+
+// InSnapshot runs the given function in a read-only transaction with the
+// REPEATABLE READ isolation level, so that all queries of the function see
+// the same snapshot of the database, regardless of concurrent updates.
+func InSnapshot(fn func(tx orm.DB) error) error {
+	return DBCon.RunInTransaction(DBCon.Context(), func(tx *pg.Tx) error {
+		if _, err := tx.Exec("SET TRANSACTION ISOLATION LEVEL REPEATABLE READ READ ONLY"); err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
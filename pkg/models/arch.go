@@ -0,0 +1,28 @@
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Contains the model of an arch
+
+package models
+
+import "strings"
+
+// The stability levels of an arch, as defined in profiles/arches.desc
+const (
+	ArchStable       = "stable"
+	ArchTransitional = "transitional"
+	ArchTesting      = "testing"
+)
+
+// Arch is an arch listed in profiles/arch.list. Position is the position
+// of the arch in the file, Status its stability level in arches.desc,
+// which is empty for arches that aren't listed there (e.g. prefix arches).
+type Arch struct {
+	Name     string `pg:",pk"`
+	Status   string
+	Position int `pg:",use_zero"`
+}
+
+// IsPrefix reports whether the arch is a prefix arch, e.g. amd64-linux
+func (a *Arch) IsPrefix() bool {
+	return strings.Contains(a.Name, "-")
+}
@@ -0,0 +1,48 @@
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Contains the model of a binary package
+
+package models
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// BinaryPackage is a prebuilt binary of a version, as listed in the
+// Packages index of a binhost. Use contains all enabled flags, including
+// implicit ones like the arch, Iuse the flags of the version.
+type BinaryPackage struct {
+	Id        string `pg:",pk"`
+	Binhost   string
+	VersionId string
+	Atom      string
+	Arch      string
+	Profile   string
+	BuildId   string
+	BuildTime time.Time
+	Iuse      []string
+	Use       []string
+	Path      string
+	Size      int64
+}
+
+// UseConfiguration returns the flags of IUSE the binary has been built
+// with, where disabled flags are prefixed with a minus. In case IUSE is
+// unknown, the enabled flags are returned.
+func (b *BinaryPackage) UseConfiguration() []string {
+	if len(b.Iuse) == 0 {
+		return b.Use
+	}
+	configuration := make([]string, 0, len(b.Iuse))
+	for _, flag := range b.Iuse {
+		flag = strings.TrimLeft(flag, "+-")
+		if slices.Contains(b.Use, flag) {
+			configuration = append(configuration, flag)
+		} else {
+			configuration = append(configuration, "-"+flag)
+		}
+	}
+	return configuration
+}
@@ -1,6 +1,8 @@
 // SPDX-License-Identifier: GPL-2.0-only
 package models
 
+import "time"
+
 type BugComponent string
 
 const (
@@ -31,6 +33,27 @@ type VersionToBug struct {
 	BugId     string
 }
 
+// PackageBugsQuery selects the atom and bug_id of the bugs of all packages,
+// that is the bugs filed against the package itself as well as the bugs
+// filed against any of its versions, e.g. stabilization or security bugs
+// listing the affected atoms
+const PackageBugsQuery = `
+	SELECT package_atom AS atom, bug_id FROM package_to_bugs
+	UNION
+	SELECT versions.atom, version_to_bugs.bug_id
+	FROM version_to_bugs JOIN versions ON versions.id = version_to_bugs.version_id
+`
+
+// StabilizationBug records when a stabilization bug has been filed for the
+// versions. Contrary to Bug, it is kept once the bug has been resolved, so
+// that the time until the versions have been stabilized can be computed.
+type StabilizationBug struct {
+	Id           string `pg:",pk"`
+	Versions     []string
+	CreationTime time.Time
+	Resolved     bool `pg:",use_zero"`
+}
+
 func (b *Bug) MatchesComponent(component BugComponent) bool {
 	if component != BugComponentGeneral {
 		return b.Component == string(component)
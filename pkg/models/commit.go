@@ -26,11 +26,14 @@ type ChangedFiles struct {
 	Added    []*ChangedFile
 	Modified []*ChangedFile
 	Deleted  []*ChangedFile
+	Renamed  []*ChangedFile `json:",omitempty"`
 }
 
 type ChangedFile struct {
 	Path       string
 	ChangeType string
+	// OldPath is the source path of a renamed or copied file
+	OldPath string `json:",omitempty"`
 }
 
 type KeywordChange struct {
@@ -43,7 +46,11 @@ type KeywordChange struct {
 	Package    *Package `pg:",fk:package_id,rel:has-one"`
 	Added      []string
 	Stabilized []string
-	All        []string
+	// Destabilized contains the testing keywords of all arches that have been stable before
+	Destabilized []string
+	// Dropped contains the keywords of all arches that are not keyworded anymore
+	Dropped []string
+	All     []string
 }
 
 type CommitToPackage struct {
@@ -0,0 +1,21 @@
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Contains the model of a developer from the developer directory
+
+package models
+
+import "time"
+
+// Developer is an entry of the developer directory, which enriches
+// the maintainers of the same email
+type Developer struct {
+	Email    string `pg:",pk"`
+	Nickname string
+	Name     string
+	Location string
+	Joined   time.Time
+	Retired  bool `pg:",use_zero"`
+	// RetiredSince is zero in case the date of the retirement is unknown
+	RetiredSince time.Time
+	Roles        []string
+}
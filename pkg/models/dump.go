@@ -0,0 +1,44 @@
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Contains the model of the manifest of the database dumps
+
+package models
+
+import "time"
+
+// DumpManifestName is the name of the manifest in the dumps directory
+const DumpManifestName = "manifest.json"
+
+// DumpManifest describes a database dump, that is the commit of the
+// repository it has been generated from, its files and their checksums,
+// as well as the tables contained in each of the files
+type DumpManifest struct {
+	Generated time.Time    `json:"generated"`
+	Commit    string       `json:"commit"`
+	Version   string       `json:"version"`
+	Files     []*DumpFile  `json:"files"`
+	Tables    []*DumpTable `json:"tables"`
+}
+
+type DumpFile struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Size        int64  `json:"size"`
+	Sha256      string `json:"sha256"`
+}
+
+type DumpTable struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Rows        int           `json:"rows"`
+	Columns     []*DumpColumn `json:"columns"`
+}
+
+// DumpColumn is a column of a dumped table. Type is one of text,
+// integer, timestamp or array, where arrays are stored as JSON
+// text and timestamps as RFC 3339 text in SQLite.
+type DumpColumn struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+}
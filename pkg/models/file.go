@@ -0,0 +1,19 @@
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Contains the model of a file installed by a package
+
+package models
+
+// PackageFile is a file installed by a version, as recorded in the
+// CONTENTS of the installed package. Name is the base name of the path,
+// Type either obj or sym, and Target the target of symlinks.
+type PackageFile struct {
+	Id        string `pg:",pk"`
+	Path      string
+	Name      string
+	Type      string
+	Target    string
+	Atom      string
+	VersionId string
+	Version   string
+}
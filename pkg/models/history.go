@@ -0,0 +1,73 @@
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Contains the models of the versioned package versions and masks
+
+package models
+
+import "time"
+
+// VersionHistory is the state of a package version between two commits.
+// The row is valid starting with the commit ValidFrom up to, but not
+// including, the commit ValidTo. Rows that are still valid don't have
+// a ValidTo. The indexes are the preceding commits of the commits, so
+// that the rows valid at a commit can be determined by comparing them.
+type VersionHistory struct {
+	Id             int64 `pg:",pk"`
+	VersionId      string
+	Category       string
+	Package        string
+	Atom           string
+	Version        string
+	Slot           string
+	Subslot        string
+	EAPI           string
+	Keywords       string
+	Description    string
+	Masks          []string `pg:",array"`
+	ValidFrom      string
+	ValidFromIndex int `pg:",use_zero"`
+	ValidTo        string
+	ValidToIndex   int
+}
+
+// MaskHistory is the state of a package mask entry between two
+// commits, analogous to VersionHistory
+type MaskHistory struct {
+	Id             int64 `pg:",pk"`
+	Versions       string
+	Author         string
+	AuthorEmail    string
+	Date           time.Time
+	Reason         string
+	ValidFrom      string
+	ValidFromIndex int `pg:",use_zero"`
+	ValidTo        string
+	ValidToIndex   int
+}
+
+// ToMask returns the mask entry described by the row
+func (h *MaskHistory) ToMask() *Mask {
+	return &Mask{
+		Versions:    h.Versions,
+		Author:      h.Author,
+		AuthorEmail: h.AuthorEmail,
+		Date:        h.Date,
+		Reason:      h.Reason,
+	}
+}
+
+// ToVersion returns the version described by the row
+func (h *VersionHistory) ToVersion() *Version {
+	return &Version{
+		Id:          h.VersionId,
+		Category:    h.Category,
+		Package:     h.Package,
+		Atom:        h.Atom,
+		Version:     h.Version,
+		Slot:        h.Slot,
+		Subslot:     h.Subslot,
+		EAPI:        h.EAPI,
+		Keywords:    h.Keywords,
+		Description: h.Description,
+	}
+}
@@ -37,6 +37,8 @@ type Maintainer struct {
 	Project Project `pg:",fk:email,rel:has-one"`
 	// In case the maintainer type is not "project", Projects will point to the projects the maintainer is member of
 	Projects []*Project `pg:"many2many:maintainer_to_projects,join_fk:project_email"`
+	// Developer is the entry of the developer directory, if there is one
+	Developer *Developer `pg:",fk:email,rel:has-one" json:",omitempty"`
 }
 
 func (m *Maintainer) PrintName() string {
@@ -46,6 +48,15 @@ func (m *Maintainer) PrintName() string {
 	return m.Email
 }
 
+// DeveloperEntry returns the entry of the developer directory
+// of the maintainer, or nil in case there is none
+func (m *Maintainer) DeveloperEntry() *Developer {
+	if m.Developer == nil || m.Developer.Email == "" {
+		return nil
+	}
+	return m.Developer
+}
+
 type MaintainerPackagesInformation struct {
 	Outdated       int
 	PullRequests   int
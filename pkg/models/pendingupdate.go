@@ -0,0 +1,17 @@
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Contains the model of an update requested by a webhook
+
+package models
+
+import "time"
+
+// PendingUpdate is an update requested by a push webhook. It
+// is picked up by the scheduler daemon, which runs the update
+// in the updater environment and deletes the request afterwards.
+type PendingUpdate struct {
+	Id         int64 `pg:",pk"`
+	Ref        string
+	Commit     string
+	ReceivedAt time.Time
+}
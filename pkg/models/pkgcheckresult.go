@@ -4,6 +4,8 @@
 
 package models
 
+import "time"
+
 type PkgCheckResult struct {
 	Id       string `pg:",pk"`
 	Atom     string
@@ -12,5 +14,17 @@ type PkgCheckResult struct {
 	Version  string
 	CPV      string
 	Class    string
-	Message  string
+	// Level is the level of the class, that is error, warning,
+	// info or style, and empty in case it is unknown
+	Level   string
+	Message string
+}
+
+// StableRequest records when pkgcheck reported a StableRequest
+// for a version for the first time, that is when the version
+// became eligible for stabilization. The rows are kept after
+// the version has been stabilized.
+type StableRequest struct {
+	VersionId string `pg:",pk"`
+	FirstSeen time.Time
 }
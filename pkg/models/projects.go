@@ -9,12 +9,25 @@ type ProjectList struct {
 }
 
 type Project struct {
-	XMLName     xml.Name `xml:"project" pg:"-"`
-	Email       string   `xml:"email" pg:",pk"`
-	Name        string   `xml:"name"`
-	Url         string   `xml:"url"`
-	Description string   `xml:"description"`
-	Members     []Member `xml:"member"`
+	XMLName     xml.Name     `xml:"project" pg:"-"`
+	Email       string       `xml:"email" pg:",pk"`
+	Name        string       `xml:"name"`
+	Url         string       `xml:"url"`
+	Description string       `xml:"description"`
+	Members     []Member     `xml:"member"`
+	Subprojects []Subproject `xml:"subproject"`
+	// InheritedMembers are the members of the subprojects inheriting
+	// their members, which aren't direct members of the project
+	InheritedMembers []Member `xml:"-"`
+}
+
+// Subproject references a project, which is part of another project.
+// In case InheritMembers is set, the members of the subproject are
+// members of the parent project as well.
+type Subproject struct {
+	XMLName        xml.Name `xml:"subproject" json:"-" pg:"-"`
+	Email          string   `xml:"ref,attr"`
+	InheritMembers bool     `xml:"inherit-members,attr"`
 }
 
 type Member struct {
@@ -25,6 +38,36 @@ type Member struct {
 	Role    string   `xml:"role"`
 }
 
+// InheritingSubprojects returns the emails of the given projects and of
+// all their subprojects inheriting the members, transitively, using the
+// given list of all projects
+func InheritingSubprojects(projects []*Project, emails []string) []string {
+	byEmail := make(map[string]*Project, len(projects))
+	for _, project := range projects {
+		byEmail[project.Email] = project
+	}
+
+	var result []string
+	seen := make(map[string]bool)
+	for len(emails) > 0 {
+		email := emails[0]
+		emails = emails[1:]
+		if seen[email] {
+			continue
+		}
+		seen[email] = true
+		result = append(result, email)
+		if project, ok := byEmail[email]; ok {
+			for _, subproject := range project.Subprojects {
+				if subproject.InheritMembers {
+					emails = append(emails, subproject.Email)
+				}
+			}
+		}
+	}
+	return result
+}
+
 type MaintainerToProject struct {
 	Id              string `pg:",pk"`
 	MaintainerEmail string
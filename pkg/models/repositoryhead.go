@@ -0,0 +1,21 @@
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Contains the model of the state of the managed repository
+
+package models
+
+import "time"
+
+// RepositoryHead is the head of the configured branch the last sync
+// has checked out. Error is set in case the last fetched head has been
+// refused, in which case Head is still the last verified head.
+type RepositoryHead struct {
+	Id       string `pg:",pk"`
+	Remote   string
+	Branch   string
+	Head     string
+	Signer   string
+	Verified bool `pg:",use_zero"`
+	Error    string
+	SyncedAt time.Time
+}
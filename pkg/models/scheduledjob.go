@@ -0,0 +1,29 @@
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Contains the model of a job run by the scheduler
+
+package models
+
+import "time"
+
+type ScheduledJobStatus string
+
+const (
+	ScheduledJobIdle     ScheduledJobStatus = "idle"
+	ScheduledJobRunning  ScheduledJobStatus = "running"
+	ScheduledJobFinished ScheduledJobStatus = "finished"
+	ScheduledJobFailed   ScheduledJobStatus = "failed"
+)
+
+// ScheduledJob is the state of a job run by the scheduler daemon
+type ScheduledJob struct {
+	Name        string `pg:",pk"`
+	Interval    string
+	After       []string
+	TriggeredBy []string
+	Status      ScheduledJobStatus
+	Error       string
+	LastStart   time.Time
+	LastFinish  time.Time
+	NextRun     time.Time
+}
@@ -0,0 +1,12 @@
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Contains the model of the full text search document of a package
+
+package models
+
+// PackageSearch is the full text search document of a package, built from
+// the name, the descriptions, the local USE flags and the upstream remote-ids
+type PackageSearch struct {
+	Atom     string `pg:",pk"`
+	Document string `pg:"type:tsvector"`
+}
@@ -0,0 +1,30 @@
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Contains the model of a run of the incremental update
+
+package models
+
+import "time"
+
+type UpdateRunStatus string
+
+const (
+	UpdateRunRunning    UpdateRunStatus = "running"
+	UpdateRunFailed     UpdateRunStatus = "failed"
+	UpdateRunFinished   UpdateRunStatus = "finished"
+	UpdateRunSuperseded UpdateRunStatus = "superseded"
+)
+
+// UpdateRun records a single run of the incremental update, that is the
+// range of commits that is imported, the phase the run is currently in
+// and its status. Unfinished runs are resumed by the next update.
+type UpdateRun struct {
+	Id          int64 `pg:",pk"`
+	StartCommit string
+	EndCommit   string
+	Phase       string
+	Status      UpdateRunStatus
+	Error       string
+	StartedAt   time.Time
+	FinishedAt  time.Time
+}
@@ -1,6 +1,4 @@
 // SPDX-License-Identifier: GPL-2.0-only
 package models
 
-var ArchesToShow = [...]string{"amd64", "x86", "alpha", "arm", "arm64", "hppa", "mips", "ppc", "ppc64", "riscv", "sparc"}
-var AllArches = [...]string{"alpha", "amd64", "arm", "arm64", "hppa", "mips", "ppc", "ppc64", "riscv", "s390", "sparc", "x86"}
 var OldCookieNames = [...]string{"search_history", "userpref_general", "userpref_packages", "userpref_maintainers", "userpref_useflags", "userpref_arches"}
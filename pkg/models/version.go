@@ -36,6 +36,7 @@ type Version struct {
 	PkgCheckResults []*PkgCheckResult    `pg:",fk:cpv"`
 	Dependencies    []*ReverseDependency `pg:",fk:reverse_dependency_version"`
 	Bugs            []*Bug               `pg:"many2many:version_to_bugs,join_fk:bug_id"`
+	BinaryPackages  []*BinaryPackage     `pg:",fk:version_id"`
 }
 
 type versionDepMap struct {
@@ -3,6 +3,8 @@ package anitya
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"net/url"
@@ -32,14 +34,12 @@ type ApiResponse struct {
 	TotalItems int          `json:"total_items"`
 }
 
-func UpdateAnitya() {
+func UpdateAnitya() error {
 	anityaPackages, err := readAllResults()
 	if err != nil {
-		slog.Error("Failed fetching anitya data", slog.Any("err", err))
-		return
+		return fmt.Errorf("failed fetching anitya data: %w", err)
 	} else if len(anityaPackages) == 0 {
-		slog.Error("No anitya packages found")
-		return
+		return errors.New("no anitya packages found")
 	}
 
 	packagesMap := make(map[string]int, len(anityaPackages))
@@ -51,16 +51,14 @@ func UpdateAnitya() {
 
 	err = database.DBCon.Model(&packages).WherePK().Relation("Versions").Select()
 	if err != nil {
-		slog.Error("Failed fetching packages", slog.Any("err", err))
-		return
+		return fmt.Errorf("failed fetching packages: %w", err)
 	}
 
 	outdatedEntries := make([]*models.OutdatedPackages, 0, len(packages))
 
 	perlConvertor, err := NewPerlVersion()
 	if err != nil {
-		slog.Error("Failed creating PerlVersion", slog.Any("err", err))
-		return
+		return fmt.Errorf("failed creating PerlVersion: %w", err)
 	}
 	defer func() {
 		err := perlConvertor.Close()
@@ -126,20 +124,19 @@ nextPackage:
 	}
 	_, err = database.DBCon.Model(&packages).Set("anitya_info = ?anitya_info").Update()
 	if err != nil {
-		slog.Error("Failed updating packages", slog.Any("err", err))
-		return
+		return fmt.Errorf("failed updating packages: %w", err)
 	}
 	slog.Info("Updated anitya information", slog.Int("count", len(packages)))
 
 	_, _ = database.DBCon.Model((*models.OutdatedPackages)(nil)).Where("source = ?", models.OutdatedSourceAnitya).Delete()
 	res, err := database.DBCon.Model(&outdatedEntries).OnConflict("(atom) DO UPDATE").Insert()
 	if err != nil {
-		slog.Error("Error while inserting outdated packages", slog.Any("err", err))
-	} else {
-		slog.Info("Inserted outdated packages", slog.Int("res", res.RowsAffected()))
+		return fmt.Errorf("error while inserting outdated packages: %w", err)
 	}
+	slog.Info("Inserted outdated packages", slog.Int("res", res.RowsAffected()))
 
 	updateStatus()
+	return nil
 }
 
 var client = http.Client{Timeout: 1 * time.Minute}
@@ -0,0 +1,198 @@
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Imports the binary packages listed in the Packages index of binhosts
+
+package binhost
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"soko/pkg/config"
+	"soko/pkg/database"
+	"soko/pkg/models"
+	"soko/pkg/portage/utils"
+
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// batchSize is the number of binary packages inserted at once
+const batchSize = 1000
+
+var client = http.Client{Timeout: 5 * time.Minute}
+
+// UpdateBinaryPackages replaces the binary packages of each configured
+// binhost with the packages listed in its Packages index. Binary packages
+// of binhosts that aren't configured anymore are deleted. A failing binhost
+// doesn't prevent the update of the other ones, the errors of all failed
+// binhosts are returned.
+func UpdateBinaryPackages() error {
+	database.Connect()
+	defer database.DBCon.Close()
+
+	var errs []error
+	binhosts := config.Binhosts()
+	for _, binhost := range binhosts {
+		binaryPackages, err := readIndex(binhost)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed reading binhost index %s: %w", binhost, err))
+			continue
+		}
+
+		err = database.InTransaction(func(tx orm.DB) error {
+			_, err := tx.Model((*models.BinaryPackage)(nil)).Where("binhost = ?", binhost).Delete()
+			if err != nil {
+				return err
+			}
+			for start := 0; start < len(binaryPackages); start += batchSize {
+				batch := binaryPackages[start:min(start+batchSize, len(binaryPackages))]
+				_, err = tx.Model(&batch).OnConflict("(id) DO UPDATE").Insert()
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed updating binary packages of %s: %w", binhost, err))
+			continue
+		}
+		slog.Info("Updated binary packages", slog.String("binhost", binhost), slog.Int("packages", len(binaryPackages)))
+	}
+
+	query := database.DBCon.Model((*models.BinaryPackage)(nil))
+	if len(binhosts) > 0 {
+		query = query.WhereIn("binhost NOT IN (?)", binhosts)
+	} else {
+		query = query.Where("TRUE")
+	}
+	if _, err := query.Delete(); err != nil {
+		errs = append(errs, fmt.Errorf("failed deleting binary packages of removed binhosts: %w", err))
+	}
+
+	_, err := database.DBCon.Model(&models.Application{
+		Id:         "binhosts",
+		LastUpdate: time.Now(),
+		Version:    config.Version(),
+	}).OnConflict("(id) DO UPDATE").Insert()
+	if err != nil {
+		slog.Error("Failed updating application data", slog.Any("err", err))
+	}
+	return errors.Join(errs...)
+}
+
+// readIndex reads the binary packages from the Packages index of
+// the binhost, which is either a URL or the path to a local file
+func readIndex(binhost string) ([]*models.BinaryPackage, error) {
+	var reader io.ReadCloser
+	if strings.HasPrefix(binhost, "http://") || strings.HasPrefix(binhost, "https://") {
+		req, err := http.NewRequest(http.MethodGet, binhost, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("User-Agent", config.UserAgent())
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		reader = resp.Body
+	} else {
+		file, err := os.Open(binhost)
+		if err != nil {
+			return nil, err
+		}
+		reader = file
+	}
+	defer reader.Close()
+	return parseIndex(reader, binhost)
+}
+
+// parseIndex parses a Packages index. The index consists of blocks of
+// 'KEY: value' lines separated by empty lines, where the first block is
+// the header, whose values apply to all packages unless overridden.
+func parseIndex(r io.Reader, binhost string) ([]*models.BinaryPackage, error) {
+	var header map[string]string
+	var binaryPackages []*models.BinaryPackage
+
+	block := map[string]string{}
+	flush := func() {
+		if len(block) == 0 {
+			return
+		}
+		if header == nil {
+			header = block
+		} else if binaryPackage := toBinaryPackage(binhost, header, block); binaryPackage != nil {
+			binaryPackages = append(binaryPackages, binaryPackage)
+		}
+		block = map[string]string{}
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+		key, value, found := strings.Cut(line, ":")
+		if found {
+			block[key] = strings.TrimSpace(value)
+		}
+	}
+	flush()
+	return binaryPackages, scanner.Err()
+}
+
+// toBinaryPackage creates the binary package of an entry of the index
+func toBinaryPackage(binhost string, header, entry map[string]string) *models.BinaryPackage {
+	value := func(key string) string {
+		if v, found := entry[key]; found {
+			return v
+		}
+		return header[key]
+	}
+
+	cpv := entry["CPV"]
+	category, pf, found := strings.Cut(cpv, "/")
+	if !found {
+		return nil
+	}
+	name, _, found := utils.SplitPF(pf)
+	if !found {
+		return nil
+	}
+
+	var buildTime time.Time
+	if seconds, err := strconv.ParseInt(entry["BUILD_TIME"], 10, 64); err == nil {
+		buildTime = time.Unix(seconds, 0)
+	}
+	size, _ := strconv.ParseInt(entry["SIZE"], 10, 64)
+
+	return &models.BinaryPackage{
+		Id:        binhost + ":" + cpv + ":" + entry["BUILD_ID"],
+		Binhost:   binhost,
+		VersionId: cpv,
+		Atom:      category + "/" + name,
+		Arch:      value("ARCH"),
+		Profile:   value("PROFILE"),
+		BuildId:   entry["BUILD_ID"],
+		BuildTime: buildTime,
+		Iuse:      strings.Fields(value("IUSE")),
+		Use:       strings.Fields(value("USE")),
+		Path:      entry["PATH"],
+		Size:      size,
+	}
+}
@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: GPL-2.0-only
+package binhost
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"soko/pkg/models"
+)
+
+func TestReadIndex(t *testing.T) {
+	const binhost = "testdata/Packages"
+	expected := []*models.BinaryPackage{
+		{
+			Id:        binhost + ":app-editors/vim-9.1.0:1",
+			Binhost:   binhost,
+			VersionId: "app-editors/vim-9.1.0",
+			Atom:      "app-editors/vim",
+			Arch:      "amd64",
+			Profile:   "default/linux/amd64/23.0",
+			BuildId:   "1",
+			BuildTime: time.Unix(1759990000, 0),
+			Iuse:      []string{"+acl", "crypt", "lua", "python", "vim-pager"},
+			Use:       []string{"abi_x86_64", "acl", "amd64", "elibc_glibc", "kernel_linux", "python"},
+			Path:      "app-editors/vim/vim-9.1.0-1.gpkg.tar",
+			Size:      2048,
+		},
+		{
+			Id:        binhost + ":app-editors/vim-9.1.0:2",
+			Binhost:   binhost,
+			VersionId: "app-editors/vim-9.1.0",
+			Atom:      "app-editors/vim",
+			Arch:      "amd64",
+			Profile:   "default/linux/amd64/23.0/desktop",
+			BuildId:   "2",
+			BuildTime: time.Unix(1759995000, 0),
+			Iuse:      []string{"+acl", "crypt", "lua", "python", "vim-pager"},
+			Use:       []string{"abi_x86_64", "acl", "amd64", "lua"},
+			Path:      "app-editors/vim/vim-9.1.0-2.gpkg.tar",
+			Size:      4096,
+		},
+		{
+			Id:        binhost + ":dev-libs/libfoo-bar-1.0-r1:1",
+			Binhost:   binhost,
+			VersionId: "dev-libs/libfoo-bar-1.0-r1",
+			Atom:      "dev-libs/libfoo-bar",
+			Arch:      "amd64",
+			Profile:   "default/linux/amd64/23.0",
+			BuildId:   "1",
+			Iuse:      []string{},
+			Use:       []string{},
+		},
+	}
+
+	got, err := readIndex(binhost)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+
+	configuration := got[0].UseConfiguration()
+	if want := []string{"acl", "-crypt", "-lua", "python", "-vim-pager"}; !reflect.DeepEqual(configuration, want) {
+		t.Errorf("Expected use configuration %v, got %v", want, configuration)
+	}
+}
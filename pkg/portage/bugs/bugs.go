@@ -5,9 +5,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"maps"
 	"net/http"
 	"net/url"
 	"regexp"
+	"slices"
 	"soko/pkg/config"
 	"soko/pkg/database"
 	"soko/pkg/models"
@@ -20,12 +22,13 @@ import (
 )
 
 type restAPIBug struct {
-	Id                 int    `json:"id"`
-	Product            string `json:"product"`
-	Status             string `json:"status"`
-	Summary            string `json:"summary"`
-	Component          string `json:"component"`
-	StabilizationAtoms string `json:"cf_stabilisation_atoms"`
+	Id                 int       `json:"id"`
+	Product            string    `json:"product"`
+	Status             string    `json:"status"`
+	Summary            string    `json:"summary"`
+	Component          string    `json:"component"`
+	StabilizationAtoms string    `json:"cf_stabilisation_atoms"`
+	CreationTime       time.Time `json:"creation_time"`
 	AssigneeDetails    struct {
 		RealName string `json:"real_name"`
 	} `json:"assigned_to_detail"`
@@ -46,7 +49,10 @@ func (b *restAPIBug) ToDBType() *models.Bug {
 	}
 }
 
-func UpdateBugs() {
+// UpdateBugs imports the bugs changed since the last update, or all
+// open bugs in case of the first update, and returns the first error
+// that prevented the import
+func UpdateBugs() error {
 	database.Connect()
 	defer database.DBCon.Close()
 
@@ -55,34 +61,33 @@ func UpdateBugs() {
 	}
 	err := database.DBCon.Model(&update).WherePK().Select()
 	if err != nil && err != pg.ErrNoRows {
-		slog.Error("Failed to fetch last update time for bugs", slog.Any("err", err))
-		return
+		return fmt.Errorf("failed to fetch last update time for bugs: %w", err)
 	}
-	if update.LastCommit != "" {
-		importAllOpenBugs()
+	if update.LastCommit != "" || err != nil {
+		err = importAllOpenBugs()
 	} else {
 		lastUpdate := update.LastUpdate
-		if err != nil {
-			importAllOpenBugs()
-		} else {
-			if time.Now().Before(lastUpdate) {
-				lastUpdate = time.Now()
-			}
-			changedSince := lastUpdate.AddDate(0, 0, -2)
-			updateChangedBugs(changedSince)
+		if time.Now().Before(lastUpdate) {
+			lastUpdate = time.Now()
 		}
+		changedSince := lastUpdate.AddDate(0, 0, -2)
+		err = updateChangedBugs(changedSince)
+	}
+	if err != nil {
+		return err
 	}
 
 	updateCategoriesInfo()
 
 	updateStatus()
+	return nil
 }
 
 func fetchBugs(changedSince *time.Time, bugStatus []string) (bugs []restAPIBug, err error) {
 	const limit = 5000
 
 	params := url.Values{
-		"include_fields": []string{"id,product,status,summary,component,assigned_to,cf_stabilisation_atoms"},
+		"include_fields": []string{"id,product,status,summary,component,assigned_to,cf_stabilisation_atoms,creation_time"},
 		"bug_status":     bugStatus,
 		"order":          []string{"changeddate DESC"},
 		"product":        []string{"Gentoo Linux", "Gentoo Security"},
@@ -103,8 +108,7 @@ func fetchBugs(changedSince *time.Time, bugStatus []string) (bugs []restAPIBug,
 		defer resp.Body.Close()
 
 		if resp.StatusCode != http.StatusOK {
-			slog.Error("Failed to fetch bugs", slog.Int("status", resp.StatusCode), slog.Int("offset", offset))
-			return bugs, nil
+			return nil, fmt.Errorf("failed to fetch bugs, offset=%d: %s", offset, resp.Status)
 		}
 
 		var response struct {
@@ -112,8 +116,7 @@ func fetchBugs(changedSince *time.Time, bugStatus []string) (bugs []restAPIBug,
 		}
 		err = json.NewDecoder(resp.Body).Decode(&response)
 		if err != nil {
-			slog.Error("Failed to decode bugs", slog.Any("err", err), slog.Int("offset", offset))
-			return bugs, nil
+			return nil, fmt.Errorf("failed to decode bugs, offset=%d: %w", offset, err)
 		}
 
 		bugs = append(bugs, response.Bugs...)
@@ -126,41 +129,35 @@ func fetchBugs(changedSince *time.Time, bugStatus []string) (bugs []restAPIBug,
 	return
 }
 
-func importAllOpenBugs() {
+func importAllOpenBugs() error {
 	slog.Info("Importing all open bugs")
 	bugs, err := fetchBugs(nil, []string{"UNCONFIRMED", "CONFIRMED", "IN_PROGRESS"})
 	if err != nil {
-		slog.Error("Failed to fetch bugs",
-			slog.String("status", "UNCONFIRMED, CONFIRMED, IN_PROGRESS"),
-			slog.Any("err", err))
-		return
+		return err
 	}
 
 	database.TruncateTable((*models.Bug)(nil))
 	database.TruncateTable((*models.PackageToBug)(nil))
 	database.TruncateTable((*models.VersionToBug)(nil))
 
-	processApiBugs(bugs)
+	return processApiBugs(bugs)
 }
 
-func updateChangedBugs(changedSince time.Time) {
+func updateChangedBugs(changedSince time.Time) error {
 	slog.Info("Updating changed bugs", slog.Time("changed_since", changedSince))
 	bugs, err := fetchBugs(&changedSince, []string{"UNCONFIRMED", "CONFIRMED", "IN_PROGRESS", "RESOLVED"})
 	if err != nil {
-		slog.Error("Failed to fetch bugs",
-			slog.String("status", "UNCONFIRMED, CONFIRMED, IN_PROGRESS, RESOLVED"),
-			slog.Time("changed_since", changedSince),
-			slog.Any("err", err))
-		return
+		return err
 	}
-	processApiBugs(bugs)
+	return processApiBugs(bugs)
 }
 
-func processApiBugs(bugs []restAPIBug) {
+func processApiBugs(bugs []restAPIBug) error {
 	var resolvedBugs []string
 	var dbBugs []*models.Bug
 	var verBugs []*models.VersionToBug
 	var pkgsBugs []*models.PackageToBug
+	var stabilizationBugs []*models.StabilizationBug
 	processedBugs := make(map[int]struct{}, len(bugs))
 
 	for _, bug := range bugs {
@@ -187,6 +184,13 @@ func processApiBugs(bugs []restAPIBug) {
 						BugId:     bugId,
 					})
 				}
+				if bug.Component == string(models.BugComponentStabilization) && len(versions) > 0 {
+					stabilizationBugs = append(stabilizationBugs, &models.StabilizationBug{
+						Id:           bugId,
+						Versions:     slices.Sorted(maps.Keys(versions)),
+						CreationTime: bug.CreationTime,
+					})
+				}
 			} else {
 				summary, _, _ := strings.Cut(strings.TrimSpace(bug.Summary), " ")
 				affectedPackage := versionSpecifierToPackageAtom(summary)
@@ -202,20 +206,24 @@ func processApiBugs(bugs []restAPIBug) {
 
 	res1, err := database.DBCon.Model(&dbBugs).OnConflict("(id) DO UPDATE").Insert()
 	if err != nil {
-		slog.Error("Failed to insert bugs", slog.Any("err", err))
-		return
+		return fmt.Errorf("failed to insert bugs: %w", err)
 	}
 
 	res2, err := database.DBCon.Model(&verBugs).OnConflict("(id) DO UPDATE").Insert()
 	if err != nil {
-		slog.Error("Failed to insert version bugs", slog.Any("err", err))
-		return
+		return fmt.Errorf("failed to insert version bugs: %w", err)
 	}
 
 	res3, err := database.DBCon.Model(&pkgsBugs).OnConflict("(id) DO UPDATE").Insert()
 	if err != nil {
-		slog.Error("Failed to insert package bugs", slog.Any("err", err))
-		return
+		return fmt.Errorf("failed to insert package bugs: %w", err)
+	}
+
+	if len(stabilizationBugs) > 0 {
+		_, err = database.DBCon.Model(&stabilizationBugs).OnConflict("(id) DO UPDATE").Insert()
+		if err != nil {
+			return fmt.Errorf("failed to insert stabilization bugs: %w", err)
+		}
 	}
 
 	slog.Info("Inserted",
@@ -226,20 +234,26 @@ func processApiBugs(bugs []restAPIBug) {
 	if len(resolvedBugs) > 0 {
 		res1, err := database.DBCon.Model((*models.Bug)(nil)).WhereIn("id IN (?)", resolvedBugs).Delete()
 		if err != nil {
-			slog.Error("Failed to delete bugs", slog.Any("err", err))
-			return
+			return fmt.Errorf("failed to delete bugs: %w", err)
 		}
 
 		res2, err := database.DBCon.Model((*models.PackageToBug)(nil)).WhereIn("bug_id IN (?)", resolvedBugs).Delete()
 		if err != nil {
-			slog.Error("Failed to delete package bugs", slog.Any("err", err))
-			return
+			return fmt.Errorf("failed to delete package bugs: %w", err)
 		}
 
 		res3, err := database.DBCon.Model((*models.VersionToBug)(nil)).WhereIn("bug_id IN (?)", resolvedBugs).Delete()
 		if err != nil {
-			slog.Error("Failed to delete version bugs", slog.Any("err", err))
-			return
+			return fmt.Errorf("failed to delete version bugs: %w", err)
+		}
+
+		// stabilization bugs are kept for the stabilization statistics
+		_, err = database.DBCon.Model((*models.StabilizationBug)(nil)).
+			Set("resolved = TRUE").
+			WhereIn("id IN (?)", resolvedBugs).
+			Update()
+		if err != nil {
+			return fmt.Errorf("failed to resolve stabilization bugs: %w", err)
 		}
 
 		slog.Info("Deleted",
@@ -247,11 +261,13 @@ func processApiBugs(bugs []restAPIBug) {
 			slog.Int("package_bugs", res2.RowsAffected()),
 			slog.Int("version_bugs", res3.RowsAffected()))
 	}
+	return nil
 }
 
 func calculateAffectedVersions(versionSpecifier string) []*models.Version {
 	packageAtom := versionSpecifierToPackageAtom(versionSpecifier)
-	return utils.CalculateAffectedVersions(versionSpecifier, packageAtom)
+	versions, _ := utils.CalculateAffectedVersions(database.DBCon, versionSpecifier, packageAtom)
+	return versions
 }
 
 var versionNumber = regexp.MustCompile(`-[0-9]`)
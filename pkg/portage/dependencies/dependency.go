@@ -18,23 +18,22 @@ import (
 	"github.com/ulikunitz/xz"
 )
 
-func FullPackageDependenciesUpdate() {
+func FullPackageDependenciesUpdate() error {
 	database.Connect()
 	defer database.DBCon.Close()
 
 	update := models.Application{Id: "dependencies"}
 	err := database.DBCon.Model(&update).WherePK().Select()
 	if err != nil && err != pg.ErrNoRows {
-		slog.Error("Failed to fetch last update time for dependencies", slog.Any("err", err))
-		return
+		return fmt.Errorf("failed to fetch last update time for dependencies: %w", err)
 	}
 
 	newLastModified, dependencies, err := UpdateDependencies(update.LastCommit)
 	if err != nil {
-		return
+		return err
 	} else if len(dependencies) == 0 {
 		slog.Info("No new dependencies to update")
-		return
+		return nil
 	}
 
 	slog.Info("collected dependencies", slog.Int("count", len(dependencies)))
@@ -44,12 +43,13 @@ func FullPackageDependenciesUpdate() {
 	// duplicates, so we can use bulk insert
 	res, err := database.DBCon.Model(&dependencies).Insert()
 	if err != nil {
-		slog.Error("Error during inserting dependencies", slog.Any("err", err))
-	} else {
-		slog.Info("Inserted dependencies", slog.Int("rows", res.RowsAffected()))
+		// the status isn't updated, so that the dependencies are fetched again
+		return fmt.Errorf("error during inserting dependencies: %w", err)
 	}
+	slog.Info("Inserted dependencies", slog.Int("rows", res.RowsAffected()))
 
 	updateStatus(newLastModified)
+	return nil
 }
 
 func UpdateDependencies(lastModified string) (newLastModified string, dependencies []*models.ReverseDependency, err error) {
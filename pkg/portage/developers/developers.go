@@ -0,0 +1,149 @@
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Imports the developers from an export of the developer directory
+
+package developers
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"soko/pkg/config"
+	"soko/pkg/database"
+	"soko/pkg/models"
+
+	"github.com/go-pg/pg/v10/orm"
+)
+
+var client = http.Client{Timeout: 1 * time.Minute}
+
+// UpdateDevelopers replaces the developers in the database with the
+// developers of config.DevelopersFile
+func UpdateDevelopers() error {
+	if config.DevelopersFile() == "" {
+		slog.Info("No developers file configured, skipping the import of the developers")
+		return nil
+	}
+
+	database.Connect()
+	defer database.DBCon.Close()
+
+	developers, err := readDevelopers(config.DevelopersFile())
+	if err != nil {
+		return fmt.Errorf("failed reading developers from %s: %w", config.DevelopersFile(), err)
+	}
+
+	err = database.InTransaction(func(tx orm.DB) error {
+		_, err := tx.Model((*models.Developer)(nil)).Where("TRUE").Delete()
+		if err != nil {
+			return err
+		}
+		if len(developers) == 0 {
+			return nil
+		}
+		_, err = tx.Model(&developers).OnConflict("(email) DO NOTHING").Insert()
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed updating developers: %w", err)
+	}
+
+	_, err = database.DBCon.Model(&models.Application{
+		Id:         "developers",
+		LastUpdate: time.Now(),
+		Version:    config.Version(),
+	}).OnConflict("(id) DO UPDATE").Insert()
+	if err != nil {
+		slog.Error("Failed updating application data", slog.Any("err", err))
+	}
+	slog.Info("Imported developers", slog.Int("developers", len(developers)))
+	return nil
+}
+
+// readDevelopers reads the developers from the given URL or local file
+func readDevelopers(path string) ([]*models.Developer, error) {
+	var reader io.ReadCloser
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("User-Agent", config.UserAgent())
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		reader = resp.Body
+	} else {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		reader = file
+	}
+	defer reader.Close()
+	return parseDevelopers(reader)
+}
+
+// parseDevelopers parses the developers, detecting the format by the
+// first character, that is '<' for XML, '[' for JSON and LDIF otherwise
+func parseDevelopers(r io.Reader) ([]*models.Developer, error) {
+	buffered := bufio.NewReader(r)
+	var first byte
+	for {
+		b, err := buffered.ReadByte()
+		if err == io.EOF {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if !strings.ContainsRune(" \t\r\n", rune(b)) {
+			first = b
+			buffered.UnreadByte()
+			break
+		}
+	}
+
+	var developers []*models.Developer
+	var err error
+	switch first {
+	case '<':
+		developers, err = parseXML(buffered)
+	case '[':
+		developers, err = parseJSON(buffered)
+	default:
+		developers, err = parseLDIF(buffered)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return uniqueDevelopers(developers), nil
+}
+
+// uniqueDevelopers drops developers without email and all but the
+// first developer of each email
+func uniqueDevelopers(developers []*models.Developer) []*models.Developer {
+	unique := make([]*models.Developer, 0, len(developers))
+	seen := make(map[string]bool, len(developers))
+	for _, developer := range developers {
+		if developer.Email == "" && developer.Nickname != "" {
+			developer.Email = developer.Nickname + "@gentoo.org"
+		}
+		if developer.Email == "" || seen[developer.Email] {
+			continue
+		}
+		seen[developer.Email] = true
+		unique = append(unique, developer)
+	}
+	return unique
+}
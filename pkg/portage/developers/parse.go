@@ -0,0 +1,239 @@
+// SPDX-License-Identifier: GPL-2.0-only
+package developers
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"strings"
+	"time"
+
+	"soko/pkg/models"
+)
+
+// dateLayouts are the accepted formats of the join and retirement dates
+var dateLayouts = []string{
+	time.DateOnly,
+	"2006/01/02",
+	time.RFC3339,
+	"20060102150405Z",
+	"20060102",
+}
+
+// parseDate parses a date in any of the dateLayouts, returning
+// the zero time in case it is empty or invalid
+func parseDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range dateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date
+		}
+	}
+	return time.Time{}
+}
+
+// splitRoles splits a comma separated list of roles
+func splitRoles(values ...string) []string {
+	var roles []string
+	for _, value := range values {
+		for role := range strings.SplitSeq(value, ",") {
+			if role = strings.TrimSpace(role); role != "" {
+				roles = append(roles, role)
+			}
+		}
+	}
+	return roles
+}
+
+// xmlUser is an entry of a userinfo.xml
+type xmlUser struct {
+	Username string `xml:"username,attr"`
+	RealName struct {
+		Fullname   string `xml:"fullname,attr"`
+		Firstname  string `xml:"firstname"`
+		Familyname string `xml:"familyname"`
+	} `xml:"realname"`
+	Email    []string `xml:"email"`
+	Location string   `xml:"location"`
+	Joined   string   `xml:"joined"`
+	Retired  string   `xml:"retired"`
+	Status   string   `xml:"status"`
+	Roles    string   `xml:"roles"`
+}
+
+// parseXML parses a userinfo.xml, that is a <userlist> of <user> entries
+func parseXML(r io.Reader) ([]*models.Developer, error) {
+	var userList struct {
+		Users []xmlUser `xml:"user"`
+	}
+	if err := xml.NewDecoder(r).Decode(&userList); err != nil {
+		return nil, err
+	}
+
+	developers := make([]*models.Developer, 0, len(userList.Users))
+	for _, user := range userList.Users {
+		name := user.RealName.Fullname
+		if name == "" {
+			name = strings.TrimSpace(user.RealName.Firstname + " " + user.RealName.Familyname)
+		}
+		developer := &models.Developer{
+			Nickname:     strings.TrimSpace(user.Username),
+			Name:         strings.TrimSpace(name),
+			Location:     strings.TrimSpace(user.Location),
+			Joined:       parseDate(user.Joined),
+			RetiredSince: parseDate(user.Retired),
+			Roles:        splitRoles(user.Roles),
+		}
+		if len(user.Email) > 0 {
+			developer.Email = strings.TrimSpace(user.Email[0])
+		}
+		developer.Retired = strings.TrimSpace(user.Retired) != "" || isRetiredStatus(user.Status)
+		developers = append(developers, developer)
+	}
+	return developers, nil
+}
+
+// jsonDeveloper is an entry of the JSON export. Retired is either
+// a boolean or the date of the retirement.
+type jsonDeveloper struct {
+	Nickname string          `json:"nickname"`
+	Name     string          `json:"name"`
+	Email    string          `json:"email"`
+	Location string          `json:"location"`
+	Joined   string          `json:"joined"`
+	Retired  json.RawMessage `json:"retired"`
+	Roles    []string        `json:"roles"`
+}
+
+// parseJSON parses a JSON array of developers
+func parseJSON(r io.Reader) ([]*models.Developer, error) {
+	var entries []jsonDeveloper
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return nil, err
+	}
+
+	developers := make([]*models.Developer, 0, len(entries))
+	for _, entry := range entries {
+		developer := &models.Developer{
+			Nickname: entry.Nickname,
+			Name:     entry.Name,
+			Email:    entry.Email,
+			Location: entry.Location,
+			Joined:   parseDate(entry.Joined),
+			Roles:    entry.Roles,
+		}
+		var retired bool
+		var retiredSince string
+		if json.Unmarshal(entry.Retired, &retired) == nil {
+			developer.Retired = retired
+		} else if json.Unmarshal(entry.Retired, &retiredSince) == nil && retiredSince != "" {
+			developer.Retired = true
+			developer.RetiredSince = parseDate(retiredSince)
+		}
+		developers = append(developers, developer)
+	}
+	return developers, nil
+}
+
+// parseLDIF parses an LDIF export of the developer directory using
+// the attributes of the Gentoo LDAP schema
+func parseLDIF(r io.Reader) ([]*models.Developer, error) {
+	var developers []*models.Developer
+	records, err := parseLDIFRecords(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		if record["uid"] == nil && record["mail"] == nil {
+			continue
+		}
+		first := func(keys ...string) string {
+			for _, key := range keys {
+				if len(record[key]) > 0 {
+					return record[key][0]
+				}
+			}
+			return ""
+		}
+		developer := &models.Developer{
+			Nickname:     first("uid"),
+			Name:         first("cn", "gecos"),
+			Email:        first("mail"),
+			Location:     first("gentoolocation", "l"),
+			Joined:       parseDate(first("gentoojoin")),
+			RetiredSince: parseDate(first("gentooretired")),
+			Roles:        splitRoles(record["gentooroles"]...),
+		}
+		developer.Retired = first("gentooretired") != "" || isRetiredStatus(first("gentoostatus"))
+		developers = append(developers, developer)
+	}
+	return developers, nil
+}
+
+// parseLDIFRecords splits the LDIF into records, which map the lower
+// case attribute names to their values. Continuation lines are joined
+// and base64 encoded values are decoded.
+func parseLDIFRecords(r io.Reader) ([]map[string][]string, error) {
+	var records []map[string][]string
+	record := map[string][]string{}
+	var line string
+
+	flushLine := func() error {
+		if line == "" {
+			return nil
+		}
+		key, value, found := strings.Cut(line, ":")
+		line = ""
+		if !found {
+			return nil
+		}
+		if strings.HasPrefix(value, ":") {
+			decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value[1:]))
+			if err != nil {
+				return err
+			}
+			value = string(decoded)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		record[key] = append(record[key], strings.TrimSpace(value))
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		switch {
+		case strings.HasPrefix(text, " "):
+			line += text[1:]
+			continue
+		case strings.HasPrefix(text, "#"):
+			continue
+		}
+		if err := flushLine(); err != nil {
+			return nil, err
+		}
+		if text == "" {
+			if len(record) > 0 {
+				records = append(records, record)
+				record = map[string][]string{}
+			}
+			continue
+		}
+		line = text
+	}
+	if err := flushLine(); err != nil {
+		return nil, err
+	}
+	if len(record) > 0 {
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// isRetiredStatus reports whether the status marks a retired developer
+func isRetiredStatus(status string) bool {
+	status = strings.ToLower(strings.TrimSpace(status))
+	return status == "retired" || status == "inactive"
+}
@@ -0,0 +1,51 @@
+// SPDX-License-Identifier: GPL-2.0-only
+package developers
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"soko/pkg/models"
+)
+
+func TestParseDevelopers(t *testing.T) {
+	larry := &models.Developer{
+		Email:    "larry@gentoo.org",
+		Nickname: "larry",
+		Name:     "Larry the Cow",
+		Location: "Pasture, Earth",
+		Joined:   time.Date(2002, 4, 1, 0, 0, 0, 0, time.UTC),
+		Roles:    []string{"Mascot", "Infrastructure"},
+	}
+	oldie := &models.Developer{
+		Email:        "oldie@gentoo.org",
+		Nickname:     "oldie",
+		Name:         "Old Developer",
+		Joined:       time.Date(2004, 2, 1, 0, 0, 0, 0, time.UTC),
+		Retired:      true,
+		RetiredSince: time.Date(2015, 6, 30, 0, 0, 0, 0, time.UTC),
+	}
+	expected := []*models.Developer{larry, oldie}
+
+	for _, file := range []string{"userinfo.xml", "developers.json", "developers.ldif"} {
+		t.Run(file, func(t *testing.T) {
+			f, err := os.Open("testdata/" + file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			got, err := parseDevelopers(f)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, expected) {
+				for _, developer := range got {
+					t.Logf("%+v", developer)
+				}
+				t.Errorf("Unexpected developers")
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Exports the database as compressed JSON Lines and SQLite dumps
+
+package dumps
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"soko/pkg/config"
+	"soko/pkg/database"
+	"soko/pkg/models"
+
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// sqliteName is the name of the SQLite database before compressing it
+const sqliteName = "soko.sqlite"
+
+// Export writes the tables as gzip compressed JSON Lines, one file per
+// table, and a gzip compressed SQLite database containing all tables to
+// config.DumpsDir. The dump is generated in a temporary directory and
+// moved into place once it is complete, the manifest last. The SQLite
+// database is created with the sqlite3 command, so that the export
+// fails if it is not installed.
+func Export() error {
+	database.Connect()
+	defer database.DBCon.Close()
+
+	if err := os.MkdirAll(config.DumpsDir(), 0755); err != nil {
+		return fmt.Errorf("failed creating dumps directory: %w", err)
+	}
+	tmpDir, err := os.MkdirTemp(config.DumpsDir(), ".export-")
+	if err != nil {
+		return fmt.Errorf("failed creating temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	manifest, err := writeDump(tmpDir)
+	if err != nil {
+		return fmt.Errorf("failed exporting the database: %w", err)
+	}
+
+	for _, file := range manifest.Files {
+		if err := os.Rename(filepath.Join(tmpDir, file.Name), filepath.Join(config.DumpsDir(), file.Name)); err != nil {
+			return fmt.Errorf("failed moving %s into place: %w", file.Name, err)
+		}
+	}
+	if err := os.Rename(filepath.Join(tmpDir, models.DumpManifestName), filepath.Join(config.DumpsDir(), models.DumpManifestName)); err != nil {
+		return fmt.Errorf("failed moving manifest into place: %w", err)
+	}
+
+	_, err = database.DBCon.Model(&models.Application{
+		Id:         "dumps",
+		LastUpdate: time.Now(),
+		LastCommit: manifest.Commit,
+		Version:    config.Version(),
+	}).OnConflict("(id) DO UPDATE").Insert()
+	if err != nil {
+		slog.Error("Failed updating application data", slog.Any("err", err))
+	}
+	slog.Info("Exported the database", slog.String("commit", manifest.Commit), slog.Int("files", len(manifest.Files)))
+	return nil
+}
+
+// writeDump writes all files of the dump as well as the manifest to dir. All
+// tables as well as the commit of the dump are read from the same snapshot
+// of the database, so that an update running in the meantime can't leave
+// the tables inconsistent with each other or with the commit.
+func writeDump(dir string) (*models.DumpManifest, error) {
+	manifest := &models.DumpManifest{
+		Generated: time.Now().UTC(),
+		Version:   config.Version(),
+	}
+
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		return nil, fmt.Errorf("sqlite3 is required for the SQLite dump: %w", err)
+	}
+	sqlite, err := newSqliteWriter(filepath.Join(dir, sqliteName))
+	if err != nil {
+		return nil, err
+	}
+	defer sqlite.abort()
+
+	err = database.InSnapshot(func(tx orm.DB) error {
+		latest := &models.Application{Id: "latest"}
+		if err := tx.Model(latest).WherePK().Select(); err != nil {
+			return fmt.Errorf("reading latest commit: %w", err)
+		}
+		manifest.Commit = latest.LastCommit
+
+		for _, t := range tables {
+			name := t.Name + ".jsonl.gz"
+			rows, err := writeTable(tx, filepath.Join(dir, name), t, sqlite)
+			if err != nil {
+				return fmt.Errorf("writing %s: %w", t.Name, err)
+			}
+			dumpTable := t.DumpTable
+			dumpTable.Rows = rows
+			manifest.Tables = append(manifest.Tables, &dumpTable)
+			manifest.Files = append(manifest.Files, &models.DumpFile{
+				Name:        name,
+				Description: "The " + t.Name + " table as gzip compressed JSON Lines",
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := sqlite.close(); err != nil {
+		return nil, fmt.Errorf("writing SQLite database: %w", err)
+	}
+	if err := compress(filepath.Join(dir, sqliteName)); err != nil {
+		return nil, fmt.Errorf("compressing SQLite database: %w", err)
+	}
+	manifest.Files = append(manifest.Files, &models.DumpFile{
+		Name:        sqliteName + ".gz",
+		Description: "All tables as gzip compressed SQLite database",
+	})
+
+	for _, file := range manifest.Files {
+		var err error
+		file.Size, file.Sha256, err = checksum(filepath.Join(dir, file.Name))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return manifest, os.WriteFile(filepath.Join(dir, models.DumpManifestName), b, 0644)
+}
+
+// writeTable writes the rows of the table read using db as JSON Lines to
+// the given path and inserts them into the SQLite database, if any. The
+// number of rows is returned.
+func writeTable(db orm.DB, path string, t *table, sqlite *sqliteWriter) (int, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	jsonl := newJsonlWriter(file)
+
+	if sqlite != nil {
+		if err := sqlite.createTable(&t.DumpTable); err != nil {
+			return 0, err
+		}
+	}
+
+	rows := 0
+	err = t.rows(db, func(values ...any) error {
+		rows++
+		if err := jsonl.write(t.Columns, values); err != nil {
+			return err
+		}
+		if sqlite != nil {
+			return sqlite.insert(&t.DumpTable, values)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err := jsonl.close(); err != nil {
+		return 0, err
+	}
+	return rows, file.Close()
+}
+
+// compress replaces the file at the given path by a gzip compressed
+// file with the suffix .gz
+func compress(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(path + ".gz")
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// checksum returns the size and the hex encoded sha256 of the file
+func checksum(path string) (int64, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: GPL-2.0-only
+package dumps
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"soko/pkg/models"
+)
+
+var testTable = &models.DumpTable{
+	Name: "versions",
+	Columns: []*models.DumpColumn{
+		column("id", "text", ""),
+		column("useflags", "array", ""),
+		column("date", "timestamp", ""),
+		column("rows", "integer", ""),
+	},
+}
+
+var testRow = []any{"app-misc/it's-1.0", []string{"+a", "b"}, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), 3}
+
+func TestJsonlWriter(t *testing.T) {
+	var out bytes.Buffer
+	jsonl := newJsonlWriter(&out)
+	if err := jsonl.write(testTable.Columns, testRow); err != nil {
+		t.Fatal(err)
+	}
+	if err := jsonl.close(); err != nil {
+		t.Fatal(err)
+	}
+	gz, err := gzip.NewReader(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(gz)
+	expected := `{"id":"app-misc/it's-1.0","useflags":["+a","b"],"date":"2024-05-01T12:00:00Z","rows":3}` + "\n"
+	if string(got) != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestSqliteWriter(t *testing.T) {
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("sqlite3 is not installed")
+	}
+	path := filepath.Join(t.TempDir(), sqliteName)
+	sqlite, err := newSqliteWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlite.abort()
+	if err := sqlite.createTable(testTable); err != nil {
+		t.Fatal(err)
+	}
+	if err := sqlite.insert(testTable, testRow); err != nil {
+		t.Fatal(err)
+	}
+	if err := sqlite.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := exec.Command("sqlite3", path, "SELECT id, useflags, date, rows + 1 FROM versions").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `app-misc/it's-1.0|["+a","b"]|2024-05-01T12:00:00Z|4`
+	if got := strings.TrimSpace(string(out)); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestParseKeyword(t *testing.T) {
+	tests := []struct {
+		keyword   string
+		arch      string
+		stability string
+	}{
+		{"amd64", "amd64", "stable"},
+		{"~arm64", "arm64", "testing"},
+		{"-sparc", "sparc", "unavailable"},
+		{"-*", "*", "unavailable"},
+		{"~amd64-linux", "amd64-linux", "testing"},
+	}
+	for _, tt := range tests {
+		arch, stability := parseKeyword(tt.keyword)
+		if arch != tt.arch || stability != tt.stability {
+			t.Errorf("parseKeyword(%q) = %q, %q, expected %q, %q", tt.keyword, arch, stability, tt.arch, tt.stability)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+// SPDX-License-Identifier: GPL-2.0-only
+package dumps
+
+import (
+	"strings"
+
+	"soko/pkg/models"
+
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// emitFunc writes a row, whose values are in the order of the columns
+type emitFunc func(values ...any) error
+
+// table is a dumped table. Its rows are streamed from the models
+// in the database, so that the dump isn't kept in memory. All rows
+// are read using the given handle, see writeDump.
+type table struct {
+	models.DumpTable
+	rows func(db orm.DB, emit emitFunc) error
+}
+
+func column(name, columnType, description string) *models.DumpColumn {
+	return &models.DumpColumn{Name: name, Type: columnType, Description: description}
+}
+
+// tables are the dumped tables in the order they are written
+var tables = []*table{
+	{
+		DumpTable: models.DumpTable{
+			Name:        "packages",
+			Description: "The packages of the repository",
+			Columns: []*models.DumpColumn{
+				column("atom", "text", "The category and name of the package"),
+				column("category", "text", "The category of the package"),
+				column("name", "text", "The name of the package"),
+				column("longdescription", "text", "The long description from metadata.xml"),
+			},
+		},
+		rows: func(db orm.DB, emit emitFunc) error {
+			return db.Model((*models.Package)(nil)).
+				Column("atom", "category", "name", "longdescription").
+				Order("atom").
+				ForEach(func(p *models.Package) error {
+					return emit(p.Atom, p.Category, p.Name, p.Longdescription)
+				})
+		},
+	},
+	{
+		DumpTable: models.DumpTable{
+			Name:        "versions",
+			Description: "The versions, that is ebuilds, of the packages",
+			Columns: []*models.DumpColumn{
+				column("id", "text", "The category, name and version of the ebuild"),
+				column("atom", "text", "The package of the version"),
+				column("version", "text", "The version including the revision"),
+				column("slot", "text", "The SLOT"),
+				column("subslot", "text", "The subslot"),
+				column("eapi", "text", "The EAPI"),
+				column("keywords", "text", "The KEYWORDS, see the keywords table for the parsed keywords"),
+				column("useflags", "array", "The IUSE including defaults"),
+				column("restricts", "array", "The RESTRICT"),
+				column("properties", "array", "The PROPERTIES"),
+				column("homepage", "array", "The HOMEPAGE"),
+				column("license", "text", "The LICENSE"),
+				column("description", "text", "The DESCRIPTION"),
+			},
+		},
+		rows: func(db orm.DB, emit emitFunc) error {
+			return db.Model((*models.Version)(nil)).
+				Order("id").
+				ForEach(func(v *models.Version) error {
+					return emit(v.Id, v.Atom, v.Version, v.Slot, v.Subslot, v.EAPI, v.Keywords,
+						v.Useflags, v.Restricts, v.Properties, v.Homepage, v.License, v.Description)
+				})
+		},
+	},
+	{
+		DumpTable: models.DumpTable{
+			Name:        "keywords",
+			Description: "The keywords of the versions, one row per arch",
+			Columns: []*models.DumpColumn{
+				column("version_id", "text", "The version"),
+				column("arch", "text", "The arch, or * for -*"),
+				column("stability", "text", "Either stable, testing or unavailable"),
+			},
+		},
+		rows: func(db orm.DB, emit emitFunc) error {
+			return db.Model((*models.Version)(nil)).
+				Column("id", "keywords").
+				Order("id").
+				ForEach(func(v *models.Version) error {
+					for keyword := range strings.FieldsSeq(v.Keywords) {
+						arch, stability := parseKeyword(keyword)
+						if err := emit(v.Id, arch, stability); err != nil {
+							return err
+						}
+					}
+					return nil
+				})
+		},
+	},
+	{
+		DumpTable: models.DumpTable{
+			Name:        "masks",
+			Description: "The entries of profiles/package.mask",
+			Columns: []*models.DumpColumn{
+				column("versions", "text", "The masked atom"),
+				column("author", "text", "The author of the mask"),
+				column("author_email", "text", "The email of the author"),
+				column("date", "timestamp", "The date of the mask"),
+				column("reason", "text", "The reason given in the comment of the mask"),
+			},
+		},
+		rows: func(db orm.DB, emit emitFunc) error {
+			return db.Model((*models.Mask)(nil)).
+				Order("versions").
+				ForEach(func(m *models.Mask) error {
+					return emit(m.Versions, m.Author, m.AuthorEmail, m.Date, m.Reason)
+				})
+		},
+	},
+	{
+		DumpTable: models.DumpTable{
+			Name:        "masked_versions",
+			Description: "The versions matched by the masks",
+			Columns: []*models.DumpColumn{
+				column("mask_versions", "text", "The masked atom, see masks"),
+				column("version_id", "text", "The masked version"),
+			},
+		},
+		rows: func(db orm.DB, emit emitFunc) error {
+			return db.Model((*models.MaskToVersion)(nil)).
+				Order("id").
+				ForEach(func(m *models.MaskToVersion) error {
+					return emit(m.MaskVersions, m.VersionId)
+				})
+		},
+	},
+	{
+		DumpTable: models.DumpTable{
+			Name:        "maintainers",
+			Description: "The maintainers of the packages from metadata.xml",
+			Columns: []*models.DumpColumn{
+				column("atom", "text", "The maintained package"),
+				column("email", "text", "The email of the maintainer"),
+				column("name", "text", "The name of the maintainer"),
+				column("type", "text", "Either person or project"),
+				column("restrict", "text", "The versions the maintainer is restricted to"),
+			},
+		},
+		rows: func(db orm.DB, emit emitFunc) error {
+			return db.Model((*models.Package)(nil)).
+				Column("atom", "maintainers").
+				Order("atom").
+				ForEach(func(p *models.Package) error {
+					for _, maintainer := range p.Maintainers {
+						if err := emit(p.Atom, maintainer.Email, maintainer.Name, maintainer.Type, maintainer.Restrict); err != nil {
+							return err
+						}
+					}
+					return nil
+				})
+		},
+	},
+	{
+		DumpTable: models.DumpTable{
+			Name:        "useflags",
+			Description: "The global, local and USE_EXPAND flags",
+			Columns: []*models.DumpColumn{
+				column("id", "text", "The unique id of the flag"),
+				column("name", "text", "The name of the flag"),
+				column("scope", "text", "Either global, local or use_expand"),
+				column("description", "text", "The description of the flag"),
+				column("use_expand", "text", "The USE_EXPAND variable of the flag"),
+				column("package", "text", "The package of local flags"),
+			},
+		},
+		rows: func(db orm.DB, emit emitFunc) error {
+			return db.Model((*models.Useflag)(nil)).
+				Order("id").
+				ForEach(func(u *models.Useflag) error {
+					return emit(u.Id, u.Name, u.Scope, u.Description, u.UseExpand, u.Package)
+				})
+		},
+	},
+	{
+		DumpTable: models.DumpTable{
+			Name:        "bugs",
+			Description: "The open bugs of the packages, see https://bugs.gentoo.org/<id>",
+			Columns: []*models.DumpColumn{
+				column("id", "text", "The id of the bug"),
+				column("product", "text", "The product of the bug"),
+				column("component", "text", "The component of the bug, e.g. Vulnerabilities"),
+				column("assignee", "text", "The assignee of the bug"),
+				column("status", "text", "The status of the bug"),
+				column("summary", "text", "The summary of the bug"),
+			},
+		},
+		rows: func(db orm.DB, emit emitFunc) error {
+			return db.Model((*models.Bug)(nil)).
+				Order("id").
+				ForEach(func(b *models.Bug) error {
+					return emit(b.Id, b.Product, b.Component, b.Assignee, b.Status, b.Summary)
+				})
+		},
+	},
+	{
+		DumpTable: models.DumpTable{
+			Name:        "package_bugs",
+			Description: "The bugs of the packages",
+			Columns: []*models.DumpColumn{
+				column("atom", "text", "The package"),
+				column("bug_id", "text", "The bug, see bugs"),
+			},
+		},
+		rows: func(db orm.DB, emit emitFunc) error {
+			return db.Model((*models.PackageToBug)(nil)).
+				Order("id").
+				ForEach(func(b *models.PackageToBug) error {
+					return emit(b.PackageAtom, b.BugId)
+				})
+		},
+	},
+	{
+		DumpTable: models.DumpTable{
+			Name:        "version_bugs",
+			Description: "The bugs of specific versions",
+			Columns: []*models.DumpColumn{
+				column("version_id", "text", "The version"),
+				column("bug_id", "text", "The bug, see bugs"),
+			},
+		},
+		rows: func(db orm.DB, emit emitFunc) error {
+			return db.Model((*models.VersionToBug)(nil)).
+				Order("id").
+				ForEach(func(b *models.VersionToBug) error {
+					return emit(b.VersionId, b.BugId)
+				})
+		},
+	},
+	{
+		DumpTable: models.DumpTable{
+			Name:        "reverse_dependencies",
+			Description: "The versions depending on the packages",
+			Columns: []*models.DumpColumn{
+				column("atom", "text", "The package that is depended on"),
+				column("type", "text", "The type of the dependency, e.g. DEPEND or RDEPEND"),
+				column("reverse_dependency_atom", "text", "The depending package"),
+				column("reverse_dependency_version", "text", "The depending version"),
+				column("condition", "text", "The USE conditions of the dependency"),
+			},
+		},
+		rows: func(db orm.DB, emit emitFunc) error {
+			return db.Model((*models.ReverseDependency)(nil)).
+				Order("id").
+				ForEach(func(d *models.ReverseDependency) error {
+					return emit(d.Atom, d.Type, d.ReverseDependencyAtom, d.ReverseDependencyVersion, d.Condition)
+				})
+		},
+	},
+}
+
+// parseKeyword splits a keyword into the arch and its stability
+func parseKeyword(keyword string) (arch, stability string) {
+	switch {
+	case strings.HasPrefix(keyword, "~"):
+		return keyword[1:], "testing"
+	case strings.HasPrefix(keyword, "-"):
+		return keyword[1:], "unavailable"
+	default:
+		return keyword, "stable"
+	}
+}
@@ -0,0 +1,154 @@
+// SPDX-License-Identifier: GPL-2.0-only
+package dumps
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"soko/pkg/models"
+)
+
+// jsonlWriter writes rows as gzip compressed JSON objects, one per line,
+// whose keys are in the order of the columns
+type jsonlWriter struct {
+	gz  *gzip.Writer
+	buf *bufio.Writer
+}
+
+func newJsonlWriter(w io.Writer) *jsonlWriter {
+	gz := gzip.NewWriter(w)
+	return &jsonlWriter{gz: gz, buf: bufio.NewWriter(gz)}
+}
+
+func (j *jsonlWriter) write(columns []*models.DumpColumn, values []any) error {
+	j.buf.WriteByte('{')
+	for i, column := range columns {
+		if i > 0 {
+			j.buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(column.Name)
+		j.buf.Write(key)
+		j.buf.WriteByte(':')
+		value, err := json.Marshal(values[i])
+		if err != nil {
+			return err
+		}
+		j.buf.Write(value)
+	}
+	j.buf.WriteString("}\n")
+	return nil
+}
+
+func (j *jsonlWriter) close() error {
+	if err := j.buf.Flush(); err != nil {
+		return err
+	}
+	return j.gz.Close()
+}
+
+// sqliteWriter creates a SQLite database by piping the statements
+// into the sqlite3 command, all within a single transaction
+type sqliteWriter struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	buf   *bufio.Writer
+	done  bool
+}
+
+func newSqliteWriter(path string) (*sqliteWriter, error) {
+	cmd := exec.Command("sqlite3", "-bail", path)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	s := &sqliteWriter{cmd: cmd, stdin: stdin, buf: bufio.NewWriter(stdin)}
+	s.buf.WriteString("PRAGMA journal_mode = OFF;\nPRAGMA synchronous = OFF;\nBEGIN;\n")
+	return s, nil
+}
+
+func (s *sqliteWriter) createTable(t *models.DumpTable) error {
+	columns := make([]string, 0, len(t.Columns))
+	for _, column := range t.Columns {
+		columnType := "TEXT"
+		if column.Type == "integer" {
+			columnType = "INTEGER"
+		}
+		columns = append(columns, sqliteIdent(column.Name)+" "+columnType)
+	}
+	_, err := s.buf.WriteString("CREATE TABLE " + sqliteIdent(t.Name) + " (" + strings.Join(columns, ", ") + ");\n")
+	return err
+}
+
+func (s *sqliteWriter) insert(t *models.DumpTable, values []any) error {
+	literals := make([]string, 0, len(values))
+	for _, value := range values {
+		literals = append(literals, sqliteLiteral(value))
+	}
+	_, err := s.buf.WriteString("INSERT INTO " + sqliteIdent(t.Name) + " VALUES (" + strings.Join(literals, ", ") + ");\n")
+	return err
+}
+
+// close commits the transaction and waits for sqlite3 to exit
+func (s *sqliteWriter) close() error {
+	s.done = true
+	s.buf.WriteString("COMMIT;\n")
+	if err := s.buf.Flush(); err != nil {
+		s.stdin.Close()
+		s.cmd.Wait()
+		return err
+	}
+	if err := s.stdin.Close(); err != nil {
+		s.cmd.Wait()
+		return err
+	}
+	return s.cmd.Wait()
+}
+
+// abort stops sqlite3 in case the database hasn't been closed
+func (s *sqliteWriter) abort() {
+	if !s.done {
+		s.done = true
+		s.cmd.Process.Kill()
+		s.cmd.Wait()
+	}
+}
+
+// sqliteIdent quotes the given identifier
+func sqliteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// sqliteLiteral converts the value to a SQLite literal. Arrays are
+// stored as JSON text and timestamps as RFC 3339 text.
+func sqliteLiteral(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	case time.Time:
+		if v.IsZero() {
+			return "NULL"
+		}
+		return sqliteLiteral(v.UTC().Format(time.RFC3339))
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "NULL"
+		}
+		return sqliteLiteral(string(b))
+	}
+}
@@ -0,0 +1,144 @@
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Imports the files installed by the packages from a CONTENTS dump
+
+package files
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"soko/pkg/config"
+	"soko/pkg/database"
+	"soko/pkg/models"
+	"soko/pkg/portage/utils"
+
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// batchSize is the number of files inserted at once
+const batchSize = 5000
+
+// UpdateFiles replaces the files in the database with the files listed in
+// the CONTENTS of the packages in config.FilesDir. Directories aren't
+// imported, as they are usually owned by several packages.
+func UpdateFiles() error {
+	if config.FilesDir() == "" {
+		slog.Info("No files directory configured, skipping the import of the files")
+		return nil
+	}
+
+	database.Connect()
+	defer database.DBCon.Close()
+
+	contents, err := filepath.Glob(filepath.Join(config.FilesDir(), "*", "*", "CONTENTS"))
+	if err != nil {
+		return fmt.Errorf("failed listing CONTENTS: %w", err)
+	}
+
+	// the files are replaced in a transaction, so that
+	// the old files are shown until the import is done
+	count := 0
+	err = database.InTransaction(func(tx orm.DB) error {
+		_, err := tx.Model((*models.PackageFile)(nil)).Where("TRUE").Delete()
+		if err != nil {
+			return fmt.Errorf("failed deleting files: %w", err)
+		}
+		for _, path := range contents {
+			dir := filepath.Dir(path)
+			category, pf := filepath.Base(filepath.Dir(dir)), filepath.Base(dir)
+			file, err := os.Open(path)
+			if err != nil {
+				slog.Error("Failed opening CONTENTS", slog.String("path", path), slog.Any("err", err))
+				continue
+			}
+			files := parseContents(file, category, pf)
+			file.Close()
+
+			for start := 0; start < len(files); start += batchSize {
+				batch := files[start:min(start+batchSize, len(files))]
+				_, err = tx.Model(&batch).OnConflict("(id) DO NOTHING").Insert()
+				if err != nil {
+					return fmt.Errorf("failed inserting files of %s: %w", category+"/"+pf, err)
+				}
+			}
+			count += len(files)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = database.DBCon.Model(&models.Application{
+		Id:         "files",
+		LastUpdate: time.Now(),
+		Version:    config.Version(),
+	}).OnConflict("(id) DO UPDATE").Insert()
+	if err != nil {
+		slog.Error("Failed updating application data", slog.Any("err", err))
+	}
+	slog.Info("Imported files", slog.Int("versions", len(contents)), slog.Int("files", count))
+	return nil
+}
+
+// parseContents parses the CONTENTS of the given version, that is lines of
+// the form 'obj <path> <md5> <mtime>', 'sym <path> -> <target> <mtime>' or
+// 'dir <path>'. Paths may contain spaces, so that the trailing fields are
+// cut off from the end of the line.
+func parseContents(r io.Reader, category, pf string) []*models.PackageFile {
+	name, version, found := utils.SplitPF(pf)
+	if !found {
+		slog.Error("Failed determining the version", slog.String("version", category+"/"+pf))
+		return nil
+	}
+	atom := category + "/" + name
+
+	var files []*models.PackageFile
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fileType, rest, _ := strings.Cut(scanner.Text(), " ")
+		var path, target string
+		switch fileType {
+		case "obj":
+			// drop md5 and mtime
+			fields := strings.Split(rest, " ")
+			if len(fields) < 3 {
+				continue
+			}
+			path = strings.Join(fields[:len(fields)-2], " ")
+		case "sym":
+			var found bool
+			path, target, found = strings.Cut(rest, " -> ")
+			if !found {
+				continue
+			}
+			// drop mtime
+			if i := strings.LastIndexByte(target, ' '); i >= 0 {
+				target = target[:i]
+			}
+		default:
+			continue
+		}
+		files = append(files, &models.PackageFile{
+			Id:        category + "/" + pf + ":" + path,
+			Path:      path,
+			Name:      filepath.Base(path),
+			Type:      fileType,
+			Target:    target,
+			Atom:      atom,
+			VersionId: category + "/" + pf,
+			Version:   version,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		slog.Error("Failed reading CONTENTS", slog.String("version", category+"/"+pf), slog.Any("err", err))
+	}
+	return files
+}
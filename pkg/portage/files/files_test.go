@@ -0,0 +1,30 @@
+// SPDX-License-Identifier: GPL-2.0-only
+package files
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"soko/pkg/models"
+)
+
+func TestParseContents(t *testing.T) {
+	contents := strings.Join([]string{
+		"dir /usr/bin",
+		"obj /usr/bin/foo d41d8cd98f00b204e9800998ecf8427e 1700000000",
+		"obj /usr/share/foo/a file d41d8cd98f00b204e9800998ecf8427e 1700000000",
+		"sym /usr/bin/bar -> foo 1700000000",
+		"obj /broken",
+	}, "\n")
+
+	expected := []*models.PackageFile{
+		{Id: "app-misc/foo-bar-1.2-r1:/usr/bin/foo", Path: "/usr/bin/foo", Name: "foo", Type: "obj", Atom: "app-misc/foo-bar", VersionId: "app-misc/foo-bar-1.2-r1", Version: "1.2-r1"},
+		{Id: "app-misc/foo-bar-1.2-r1:/usr/share/foo/a file", Path: "/usr/share/foo/a file", Name: "a file", Type: "obj", Atom: "app-misc/foo-bar", VersionId: "app-misc/foo-bar-1.2-r1", Version: "1.2-r1"},
+		{Id: "app-misc/foo-bar-1.2-r1:/usr/bin/bar", Path: "/usr/bin/bar", Name: "bar", Type: "sym", Target: "foo", Atom: "app-misc/foo-bar", VersionId: "app-misc/foo-bar-1.2-r1", Version: "1.2-r1"},
+	}
+	got := parseContents(strings.NewReader(contents), "app-misc", "foo-bar-1.2-r1")
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+}
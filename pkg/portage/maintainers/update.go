@@ -2,6 +2,7 @@
 package maintainers
 
 import (
+	"fmt"
 	"log/slog"
 	"soko/pkg/config"
 	"soko/pkg/database"
@@ -9,6 +10,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 )
@@ -17,146 +20,162 @@ const maintainerNeededEmail = "maintainer-needed@gentoo.org"
 
 var caser = cases.Title(language.English)
 
-func FullImport() {
+// FullImport recomputes all maintainers and their counters
+func FullImport() error {
 	database.Connect()
 	defer database.DBCon.Close()
 
-	slog.Info("Loading all raw maintainers from the database")
-	var allMaintainerInformation []*models.Maintainer
-	_ = database.DBCon.Model((*models.Package)(nil)).ColumnExpr("jsonb_array_elements(maintainers)->>'Name' as name, jsonb_array_elements(maintainers) ->> 'Email' as email, jsonb_array_elements(maintainers) ->> 'Type' as type").Select(&allMaintainerInformation)
-
-	maintainers := map[string]*models.Maintainer{
-		maintainerNeededEmail: {
-			Email: maintainerNeededEmail,
-		},
+	if err := database.InTransaction(UpdateMaintainers); err != nil {
+		return fmt.Errorf("failed updating maintainers: %w", err)
 	}
 
-	for _, rawMaintainer := range allMaintainerInformation {
-		_, ok := maintainers[rawMaintainer.Email]
-		if !ok {
-			maintainers[rawMaintainer.Email] = rawMaintainer
-		} else if maintainers[rawMaintainer.Email].Name == "" {
-			maintainers[rawMaintainer.Email].Name = rawMaintainer.Name
-		}
-	}
+	updateStatus()
+	return nil
+}
+
+// maintainerCounters is a maintainer and the counters of its packages,
+// as computed by maintainersQuery
+type maintainerCounters struct {
+	Email          string
+	Name           string
+	Type           string
+	Outdated       int
+	PullRequests   int
+	Bugs           int
+	SecurityBugs   int
+	StableRequests int
+}
 
-	slog.Info("Loading all packages from the database")
-	var gpackages []*models.Package
-	err := database.DBCon.Model(&gpackages).
-		Relation("Outdated").
-		Relation("PullRequests").
-		Relation("Bugs").
-		Relation("Versions").
-		Relation("Versions.Bugs").
-		Relation("Versions.PkgCheckResults").
-		Select()
+// maintainersQuery computes the counters of all maintainers in a single
+// query. Packages without maintainers are maintained by maintainer-needed.
+// Bugs and pull requests affecting several packages of a maintainer are
+// only counted once.
+const maintainersQuery = `
+WITH raw_maintainers AS (
+	SELECT packages.atom, maintainer ->> 'Email' AS email, maintainer ->> 'Name' AS name, maintainer ->> 'Type' AS type
+	FROM packages, JSONB_ARRAY_ELEMENTS(COALESCE(NULLIF(packages.maintainers, 'null'), '[]')) AS maintainer
+	UNION ALL
+	SELECT packages.atom, ?0, '', ''
+	FROM packages
+	WHERE COALESCE(JSONB_ARRAY_LENGTH(NULLIF(packages.maintainers, 'null')), 0) = 0
+), package_maintainers AS (
+	SELECT DISTINCT atom, email FROM raw_maintainers
+), package_bugs AS (` + models.PackageBugsQuery + `), outdated AS (
+	SELECT package_maintainers.email, COUNT(*) AS count
+	FROM package_maintainers JOIN outdated_packages ON outdated_packages.atom = package_maintainers.atom
+	GROUP BY package_maintainers.email
+), pull_requests AS (
+	SELECT package_maintainers.email, COUNT(DISTINCT package_to_pull_requests.pull_request_id) AS count
+	FROM package_maintainers JOIN package_to_pull_requests ON package_to_pull_requests.package_atom = package_maintainers.atom
+	GROUP BY package_maintainers.email
+), bugs AS (
+	SELECT package_maintainers.email,
+		COUNT(DISTINCT bugs.id) FILTER (WHERE bugs.component IS DISTINCT FROM ?1) AS count,
+		COUNT(DISTINCT bugs.id) FILTER (WHERE bugs.component = ?1) AS security_count
+	FROM package_maintainers
+	JOIN package_bugs ON package_bugs.atom = package_maintainers.atom
+	JOIN bugs ON bugs.id = package_bugs.bug_id
+	GROUP BY package_maintainers.email
+), stable_requests AS (
+	SELECT package_maintainers.email, COUNT(*) AS count
+	FROM package_maintainers
+	JOIN versions ON versions.atom = package_maintainers.atom
+	JOIN pkg_check_results ON pkg_check_results.cpv = versions.id
+	WHERE pkg_check_results.class = 'StableRequest'
+	GROUP BY package_maintainers.email
+), maintainers AS (
+	SELECT email, MAX(NULLIF(TRIM(name), '')) AS name, MAX(NULLIF(type, '')) AS type
+	FROM raw_maintainers
+	WHERE email IS NOT NULL
+	GROUP BY email
+	UNION ALL
+	SELECT ?0, NULL, NULL
+	WHERE NOT EXISTS (SELECT 1 FROM raw_maintainers WHERE email = ?0)
+)
+SELECT maintainers.email,
+	COALESCE(maintainers.name, '') AS name,
+	COALESCE(maintainers.type, '') AS type,
+	COALESCE(outdated.count, 0) AS outdated,
+	COALESCE(pull_requests.count, 0) AS pull_requests,
+	COALESCE(bugs.count, 0) AS bugs,
+	COALESCE(bugs.security_count, 0) AS security_bugs,
+	COALESCE(stable_requests.count, 0) AS stable_requests
+FROM maintainers
+LEFT JOIN outdated USING (email)
+LEFT JOIN pull_requests USING (email)
+LEFT JOIN bugs USING (email)
+LEFT JOIN stable_requests USING (email)`
+
+// UpdateMaintainers computes the counters of all maintainers in the
+// database and updates the maintainers in place, deleting the ones that
+// don't maintain any package anymore. As there is no point in time where
+// the table is empty, this can be run after every update. All queries
+// are done using the given handle.
+func UpdateMaintainers(db orm.DB) error {
+	var counters []*maintainerCounters
+	_, err := db.Query(&counters, maintainersQuery, maintainerNeededEmail, models.BugComponentVulnerabilities)
 	if err != nil {
-		slog.Error("Failed fetching packages", slog.Any("err", err))
-		return
+		return err
 	}
 
-	for _, maintainer := range maintainers {
-		var outdated, stableRequests int
-		pullRequestIds := make(map[string]struct{})
-		maintainerPackages := []*models.Package{}
-
-		for _, gpackage := range gpackages {
-			found := false
-			if len(gpackage.Maintainers) == 0 && maintainer.Email == maintainerNeededEmail {
-				found = true
-			} else {
-				for _, packageMaintainer := range gpackage.Maintainers {
-					if packageMaintainer.Email == maintainer.Email {
-						found = true
-					}
-				}
-			}
-
-			if found {
-				maintainerPackages = append(maintainerPackages, gpackage)
-
-				outdated += len(gpackage.Outdated)
-
-				for _, pullRequest := range gpackage.PullRequests {
-					pullRequestIds[pullRequest.Id] = struct{}{}
-				}
-
-				// Find Stable Requests
-				for _, version := range gpackage.Versions {
-					for _, pkgcheckWarning := range version.PkgCheckResults {
-						if pkgcheckWarning.Class == "StableRequest" {
-							stableRequests++
-						}
-					}
-				}
-			}
-		}
-
-		securityBugs, nonSecurityBugs := countBugs(maintainerPackages)
-
-		maintainer.PackagesInformation = models.MaintainerPackagesInformation{
-			Outdated:       outdated,
-			PullRequests:   len(pullRequestIds),
-			Bugs:           nonSecurityBugs,
-			SecurityBugs:   securityBugs,
-			StableRequests: stableRequests,
-		}
-
-		maintainer.Name = strings.TrimSpace(maintainer.Name)
-
-		if maintainer.Name == "" {
-			name, _, _ := strings.Cut(maintainer.Email, "@")
-			maintainer.Name = caser.String(name)
-		}
-
-		if maintainer.Type == "project" && strings.HasPrefix(maintainer.Name, "Gentoo ") {
-			maintainer.Name = strings.TrimPrefix(maintainer.Name, "Gentoo ")
-		} else if maintainer.Type == "person" {
-			if strings.HasSuffix(maintainer.Email, "@gentoo.org") {
-				maintainer.Type = "gentoo-developer"
-			} else {
-				maintainer.Type = "proxied-maintainer"
-			}
-		}
-
+	rows := make([]*models.Maintainer, 0, len(counters))
+	emails := make([]string, 0, len(counters))
+	for _, c := range counters {
+		rows = append(rows, newMaintainer(c))
+		emails = append(emails, c.Email)
 	}
 
-	// TODO in future we want an incremental update here
-	// but for now we delete everything and insert it again
-	// this is currently acceptable as it takes less than 2 seconds
-	database.TruncateTable((*models.Maintainer)(nil))
-
-	rows := make([]*models.Maintainer, 0, len(maintainers))
-	for _, row := range maintainers {
-		rows = append(rows, row)
+	if len(rows) > 0 {
+		_, err = db.Model(&rows).
+			OnConflict("(email) DO UPDATE").
+			Set("name = EXCLUDED.name, type = EXCLUDED.type, packages_information = EXCLUDED.packages_information").
+			Insert()
+		if err != nil {
+			return err
+		}
 	}
-	res, err := database.DBCon.Model(&rows).OnConflict("(email) DO NOTHING").Insert()
+	res, err := db.Model((*models.Maintainer)(nil)).
+		Where("email NOT IN (?)", pg.In(emails)).
+		Delete()
 	if err != nil {
-		slog.Error("Failed inserting maintainers", slog.Any("err", err))
-		return
+		return err
 	}
-	slog.Info("Inserted maintainers", slog.Int("rows", res.RowsAffected()))
-
-	updateStatus()
+	slog.Info("Updated maintainers", slog.Int("rows", len(rows)), slog.Int("deleted", res.RowsAffected()))
+	return nil
 }
 
-func countBugs(packages []*models.Package) (securityBugs, nonSecurityBugs int) {
-	allBugs := make(map[string]*models.Bug)
-	for _, gpackage := range packages {
-		for _, bug := range gpackage.AllBugs() {
-			allBugs[bug.Id] = bug
-		}
+// newMaintainer creates the maintainer of the given counters, deriving
+// a name from the email if there is none and distinguishing developers
+// from proxied maintainers
+func newMaintainer(c *maintainerCounters) *models.Maintainer {
+	maintainer := &models.Maintainer{
+		Email: c.Email,
+		Name:  strings.TrimSpace(c.Name),
+		Type:  c.Type,
+		PackagesInformation: models.MaintainerPackagesInformation{
+			Outdated:       c.Outdated,
+			PullRequests:   c.PullRequests,
+			Bugs:           c.Bugs,
+			SecurityBugs:   c.SecurityBugs,
+			StableRequests: c.StableRequests,
+		},
 	}
 
-	for _, bug := range allBugs {
-		if bug.Component == string(models.BugComponentVulnerabilities) {
-			securityBugs++
+	if maintainer.Name == "" {
+		name, _, _ := strings.Cut(maintainer.Email, "@")
+		maintainer.Name = caser.String(name)
+	}
+
+	if maintainer.Type == "project" && strings.HasPrefix(maintainer.Name, "Gentoo ") {
+		maintainer.Name = strings.TrimPrefix(maintainer.Name, "Gentoo ")
+	} else if maintainer.Type == "person" {
+		if strings.HasSuffix(maintainer.Email, "@gentoo.org") {
+			maintainer.Type = "gentoo-developer"
 		} else {
-			nonSecurityBugs++
+			maintainer.Type = "proxied-maintainer"
 		}
 	}
-
-	return
+	return maintainer
 }
 
 func updateStatus() {
@@ -0,0 +1,32 @@
+// SPDX-License-Identifier: GPL-2.0-only
+package maintainers
+
+import (
+	"testing"
+)
+
+func TestNewMaintainer(t *testing.T) {
+	tests := []struct {
+		name         string
+		counters     maintainerCounters
+		expectedName string
+		expectedType string
+	}{
+		{"developer", maintainerCounters{Email: "larry@gentoo.org", Name: " Larry the Cow ", Type: "person"}, "Larry the Cow", "gentoo-developer"},
+		{"proxied", maintainerCounters{Email: "jane@example.org", Type: "person"}, "Jane", "proxied-maintainer"},
+		{"project", maintainerCounters{Email: "python@gentoo.org", Name: "Gentoo Python", Type: "project"}, "Python", "project"},
+		{"maintainer needed", maintainerCounters{Email: maintainerNeededEmail}, "Maintainer-Needed", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.counters.Bugs = 2
+			got := newMaintainer(&tt.counters)
+			if got.Name != tt.expectedName || got.Type != tt.expectedType {
+				t.Errorf("Expected %q, %q, got %q, %q", tt.expectedName, tt.expectedType, got.Name, got.Type)
+			}
+			if got.PackagesInformation.Bugs != 2 {
+				t.Errorf("Expected the counters to be kept, got %+v", got.PackagesInformation)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+// SPDX-License-Identifier: GPL-2.0-only
+package pkgcheck
+
+import (
+	"bufio"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// levelsScript prints the name and level of each keyword, that is
+// result class, known to the installed pkgcheck
+const levelsScript = `from pkgcheck import objects
+for name, keyword in objects.KEYWORDS.items():
+    print(name, keyword.level)`
+
+// keywordLevels returns the level of each pkgcheck keyword, which is
+// read from the pkgcheck installation of the updater image
+func keywordLevels() (map[string]string, error) {
+	out, err := exec.Command("python3", "-c", levelsScript).Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseKeywordLevels(strings.NewReader(string(out)))
+}
+
+// parseKeywordLevels parses lines of the form '<keyword> <level>'
+func parseKeywordLevels(r io.Reader) (map[string]string, error) {
+	levels := map[string]string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		name, level, found := strings.Cut(strings.TrimSpace(scanner.Text()), " ")
+		if found && level != "None" {
+			levels[name] = level
+		}
+	}
+	return levels, scanner.Err()
+}
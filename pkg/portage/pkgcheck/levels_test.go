@@ -0,0 +1,32 @@
+// SPDX-License-Identifier: GPL-2.0-only
+package pkgcheck
+
+import (
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestParseKeywordLevels(t *testing.T) {
+	out := strings.Join([]string{
+		"NonexistentDeps error",
+		"RedundantVersion info",
+		"VariableOrderWrong style",
+		"DeprecatedEclass warning",
+		"AbstractResult None",
+		"",
+	}, "\n")
+	expected := map[string]string{
+		"NonexistentDeps":    "error",
+		"RedundantVersion":   "info",
+		"VariableOrderWrong": "style",
+		"DeprecatedEclass":   "warning",
+	}
+	got, err := parseKeywordLevels(strings.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !maps.Equal(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
@@ -3,6 +3,7 @@ package pkgcheck
 
 import (
 	"encoding/xml"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"soko/pkg/config"
@@ -28,15 +29,20 @@ type PkgCheckResult struct {
 }
 
 // UpdatePkgCheckResults will update the database table that contains all pkgcheck results
-func UpdatePkgCheckResults() {
+func UpdatePkgCheckResults() error {
 	database.Connect()
 	defer database.DBCon.Close()
 
 	// get the pkg check results from qa-reports.gentoo.org
 	pkgCheckResults, err := parseQAReport()
 	if err != nil {
-		slog.Error("Failed parsing qa-reports data", slog.Any("err", err))
-		return
+		return fmt.Errorf("failed parsing qa-reports data: %w", err)
+	}
+
+	// the report doesn't contain the levels of the classes
+	levels, err := keywordLevels()
+	if err != nil {
+		slog.Warn("Failed reading the levels of the pkgcheck keywords", slog.Any("err", err))
 	}
 
 	collected := make(map[string]*models.PkgCheckResult, len(pkgCheckResults))
@@ -56,6 +62,7 @@ func UpdatePkgCheckResults() {
 			Version:  pkgCheckResult.Version,
 			CPV:      catpkgver,
 			Class:    pkgCheckResult.Class,
+			Level:    levels[pkgCheckResult.Class],
 			Message:  pkgCheckResult.Message,
 		}
 	}
@@ -70,14 +77,16 @@ func UpdatePkgCheckResults() {
 	}
 	res, err := database.DBCon.Model(&rows).OnConflict("(id) DO NOTHING").Insert()
 	if err != nil {
-		slog.Error("Failed inserting pkgcheck results", slog.Any("err", err))
-		return
+		return fmt.Errorf("failed inserting pkgcheck results: %w", err)
 	}
 	slog.Info("Inserted pkgcheck results", slog.Int("rows", res.RowsAffected()))
 
+	updateStableRequests(rows)
+
 	updateCategoriesInfo()
 
 	updateStatus()
+	return nil
 }
 
 // parseQAReport gets the xml from qa-reports.gentoo.org and parses it
@@ -92,6 +101,32 @@ func parseQAReport() ([]PkgCheckResult, error) {
 	return pkgCheckResults.Results, err
 }
 
+// updateStableRequests records the versions that are reported
+// by a StableRequest for the first time
+func updateStableRequests(results []*models.PkgCheckResult) {
+	now := time.Now()
+	seen := map[string]bool{}
+	var stableRequests []*models.StableRequest
+	for _, result := range results {
+		if result.Class == "StableRequest" && result.Version != "" && !seen[result.CPV] {
+			seen[result.CPV] = true
+			stableRequests = append(stableRequests, &models.StableRequest{
+				VersionId: result.CPV,
+				FirstSeen: now,
+			})
+		}
+	}
+	if len(stableRequests) == 0 {
+		return
+	}
+	res, err := database.DBCon.Model(&stableRequests).OnConflict("(version_id) DO NOTHING").Insert()
+	if err != nil {
+		slog.Error("Failed inserting stable requests", slog.Any("err", err))
+		return
+	}
+	slog.Info("Inserted new stable requests", slog.Int("rows", res.RowsAffected()))
+}
+
 func updateCategoriesInfo() {
 	var categoriesInfoArr []*models.CategoryPackagesInformation
 	err := database.DBCon.Model((*models.PkgCheckResult)(nil)).
@@ -3,8 +3,11 @@ package projects
 
 import (
 	"encoding/xml"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"slices"
 	"soko/pkg/config"
 	"soko/pkg/database"
 	"soko/pkg/models"
@@ -12,21 +15,22 @@ import (
 )
 
 // UpdateProjects will update the database table that contains all projects
-func UpdateProjects() {
+func UpdateProjects() error {
 	database.Connect()
 	defer database.DBCon.Close()
 
 	// get projects from api.gentoo.org
 	projectList, err := parseProjectList()
 	if err != nil {
-		slog.Error("Error while parsing project list", slog.Any("err", err))
-		return
+		return fmt.Errorf("error while parsing project list: %w", err)
 	}
 
+	setInheritedMembers(projectList)
+
 	var members []*models.MaintainerToProject
 	membersMap := make(map[string]struct{})
 	for _, project := range projectList {
-		for _, member := range project.Members {
+		for _, member := range slices.Concat(project.Members, project.InheritedMembers) {
 			id := member.Email + "|" + project.Email
 			if _, ok := membersMap[id]; ok {
 				continue
@@ -47,16 +51,15 @@ func UpdateProjects() {
 	// insert new project list
 	_, err = database.DBCon.Model(&projectList).Insert()
 	if err != nil {
-		slog.Error("Error while inserting project list", slog.Any("err", err))
-		return
+		return fmt.Errorf("error while inserting project list: %w", err)
 	}
 	_, err = database.DBCon.Model(&members).Insert()
 	if err != nil {
-		slog.Error("Error while inserting project members", slog.Any("err", err))
-		return
+		return fmt.Errorf("error while inserting project members: %w", err)
 	}
 
 	updateStatus()
+	return nil
 }
 
 // parseProjectList gets the xml from api.gentoo.org and parses it
@@ -66,9 +69,13 @@ func parseProjectList() ([]models.Project, error) {
 		return nil, err
 	}
 	defer resp.Body.Close()
+	return decodeProjectList(resp.Body)
+}
 
+// decodeProjectList decodes the projects.xml, skipping duplicate projects
+func decodeProjectList(r io.Reader) ([]models.Project, error) {
 	var projectList models.ProjectList
-	err = xml.NewDecoder(resp.Body).Decode(&projectList)
+	err := xml.NewDecoder(r).Decode(&projectList)
 	if err != nil {
 		return nil, err
 	}
@@ -84,6 +91,37 @@ func parseProjectList() ([]models.Project, error) {
 	return uniqueProjects, nil
 }
 
+// setInheritedMembers sets the inherited members of the projects, that is
+// the members of all subprojects inheriting their members, transitively
+func setInheritedMembers(projectList []models.Project) {
+	projects := make([]*models.Project, len(projectList))
+	byEmail := make(map[string]*models.Project, len(projectList))
+	for i := range projectList {
+		projects[i] = &projectList[i]
+		byEmail[projectList[i].Email] = projects[i]
+	}
+
+	for _, project := range projects {
+		seen := make(map[string]bool, len(project.Members))
+		for _, member := range project.Members {
+			seen[member.Email] = true
+		}
+		project.InheritedMembers = nil
+		for _, email := range models.InheritingSubprojects(projects, []string{project.Email})[1:] {
+			subproject, ok := byEmail[email]
+			if !ok {
+				continue
+			}
+			for _, member := range subproject.Members {
+				if !seen[member.Email] {
+					seen[member.Email] = true
+					project.InheritedMembers = append(project.InheritedMembers, member)
+				}
+			}
+		}
+	}
+}
+
 func updateStatus() {
 	_, err := database.DBCon.Model(&models.Application{
 		Id:         "projects",
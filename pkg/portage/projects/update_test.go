@@ -0,0 +1,45 @@
+// SPDX-License-Identifier: GPL-2.0-only
+package projects
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestSetInheritedMembers(t *testing.T) {
+	file, err := os.Open("testdata/projects.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	projectList, err := decodeProjectList(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(projectList) != 4 {
+		t.Fatalf("Expected 4 unique projects, got %d", len(projectList))
+	}
+	if !projectList[0].Subprojects[0].InheritMembers || projectList[0].Subprojects[1].InheritMembers {
+		t.Errorf("Unexpected subprojects %+v", projectList[0].Subprojects)
+	}
+
+	setInheritedMembers(projectList)
+
+	// the cycle between pypy and pypy-arm must not be followed forever
+	expected := map[string][]string{
+		"python@gentoo.org":   {"pypy-dev@gentoo.org", "arm-dev@gentoo.org"},
+		"pypy@gentoo.org":     {"arm-dev@gentoo.org"},
+		"pypy-arm@gentoo.org": {"lead@gentoo.org", "pypy-dev@gentoo.org"},
+		"science@gentoo.org":  nil,
+	}
+	for _, project := range projectList {
+		var got []string
+		for _, member := range project.InheritedMembers {
+			got = append(got, member.Email)
+		}
+		if !reflect.DeepEqual(got, expected[project.Email]) {
+			t.Errorf("Expected inherited members %v of %s, got %v", expected[project.Email], project.Email, got)
+		}
+	}
+}
@@ -2,6 +2,7 @@
 package pullrequests
 
 import (
+	"fmt"
 	"iter"
 	"log/slog"
 	"slices"
@@ -14,16 +15,19 @@ import (
 	"time"
 )
 
-func FullUpdatePullRequests() {
+func FullUpdatePullRequests() error {
 	database.Connect()
 	defer database.DBCon.Close()
 
 	database.TruncateTable((*models.PullRequest)(nil))
 	database.TruncateTable((*models.PackageToPullRequest)(nil))
 
-	updatePullRequests()
+	if err := updatePullRequests(); err != nil {
+		return err
+	}
 
 	updateStatus()
+	return nil
 }
 
 var fetchers = [...]func() iter.Seq[models.PullRequestProvider]{
@@ -31,7 +35,7 @@ var fetchers = [...]func() iter.Seq[models.PullRequestProvider]{
 	github.FetchPullRequests,
 }
 
-func updatePullRequests() {
+func updatePullRequests() error {
 	categoriesPullRequests := make(map[string]map[string]struct{})
 	pullRequestsRows := make([]*models.PullRequest, 0, 1_000)
 	var pkgsPullRequests []*models.PackageToPullRequest
@@ -68,24 +72,23 @@ func updatePullRequests() {
 
 	if len(pullRequestsRows) == 0 {
 		slog.Info("No pull requests to insert")
-		return
+		return nil
 	}
 
 	result, err := database.DBCon.Model(&pullRequestsRows).OnConflict("(id) DO UPDATE").Insert()
 	if err != nil {
-		slog.Error("Failed to insert pull requests", slog.Any("err", err))
-		return
+		return fmt.Errorf("failed to insert pull requests: %w", err)
 	}
 	slog.Info("Inserted pull requests", slog.Int("rows", result.RowsAffected()))
 
 	result, err = database.DBCon.Model(&pkgsPullRequests).OnConflict("(id) DO UPDATE").Insert()
 	if err != nil {
-		slog.Error("Failed to insert packages to pull requests", slog.Any("err", err))
-		return
+		return fmt.Errorf("failed to insert packages to pull requests: %w", err)
 	}
 	slog.Info("Inserted packages to pull requests", slog.Int("rows", result.RowsAffected()))
 
 	updateCategoriesPullRequests(categoriesPullRequests)
+	return nil
 }
 
 func updateCategoriesPullRequests(categoriesPullRequests map[string]map[string]struct{}) {
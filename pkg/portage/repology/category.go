@@ -2,13 +2,13 @@
 package repology
 
 import (
-	"log/slog"
+	"fmt"
 
 	"soko/pkg/database"
 	"soko/pkg/models"
 )
 
-func UpdateCategoriesMetadata() {
+func UpdateCategoriesMetadata() error {
 	var categoriesInfoArr []*models.CategoryPackagesInformation
 	err := database.DBCon.Model((*models.OutdatedPackages)(nil)).
 		ColumnExpr("SPLIT_PART(atom, '/', 1) as name").
@@ -16,8 +16,7 @@ func UpdateCategoriesMetadata() {
 		GroupExpr("SPLIT_PART(atom, '/', 1)").
 		Select(&categoriesInfoArr)
 	if err != nil {
-		slog.Error("Failed collecting outdated stats", slog.Any("err", err))
-		return
+		return fmt.Errorf("failed collecting outdated stats: %w", err)
 	}
 	categoriesInfo := make(map[string]*models.CategoryPackagesInformation, len(categoriesInfoArr))
 	for _, categoryInfo := range categoriesInfoArr {
@@ -29,8 +28,7 @@ func UpdateCategoriesMetadata() {
 	var categories []*models.CategoryPackagesInformation
 	err = database.DBCon.Model(&categories).Column("name").Select()
 	if err != nil {
-		slog.Error("Failed fetching categories packages information", slog.Any("err", err))
-		return
+		return fmt.Errorf("failed fetching categories packages information: %w", err)
 	} else if len(categories) > 0 {
 		for _, category := range categories {
 			if info, found := categoriesInfo[category.Name]; found {
@@ -42,7 +40,7 @@ func UpdateCategoriesMetadata() {
 		}
 		_, err = database.DBCon.Model(&categories).Set("outdated = ?outdated").Update()
 		if err != nil {
-			slog.Error("Failed updating categories packages information", slog.Any("err", err))
+			return fmt.Errorf("failed updating categories packages information: %w", err)
 		}
 		categories = make([]*models.CategoryPackagesInformation, 0, len(categoriesInfo))
 	}
@@ -53,7 +51,8 @@ func UpdateCategoriesMetadata() {
 	if len(categories) > 0 {
 		_, err = database.DBCon.Model(&categories).Insert()
 		if err != nil {
-			slog.Error("Failed inserting categories packages information", slog.Any("err", err))
+			return fmt.Errorf("failed inserting categories packages information: %w", err)
 		}
 	}
+	return nil
 }
@@ -33,7 +33,7 @@ var client = http.Client{Timeout: 1 * time.Minute}
 var clientRateLimiter = rate.NewLimiter(rate.Every(2*time.Second), 1)
 
 // UpdateOutdated will update the database table that contains all outdated gentoo versions
-func UpdateOutdated() {
+func UpdateOutdated() error {
 	// Get all outdated Versions
 	outdated := newOutdatedCheck()
 	for letter := 'a'; letter <= 'z'; letter++ {
@@ -47,13 +47,13 @@ func UpdateOutdated() {
 
 		res, err := database.DBCon.Model(&outdated.outdatedVersions).OnConflict("(atom) DO NOTHING").Insert()
 		if err != nil {
-			slog.Error("Error while inserting outdated packages", slog.Any("err", err))
-		} else {
-			slog.Info("Inserted outdated packages", slog.Int("res", res.RowsAffected()))
+			return fmt.Errorf("error while inserting outdated packages: %w", err)
 		}
+		slog.Info("Inserted outdated packages", slog.Int("res", res.RowsAffected()))
 	}
 
 	updateStatus()
+	return nil
 }
 
 type atomOutdatedRules struct {
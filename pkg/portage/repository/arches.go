@@ -0,0 +1,101 @@
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Contains functions to import the arches into the database
+
+package repository
+
+import (
+	"log/slog"
+	"strings"
+
+	"soko/pkg/config"
+	"soko/pkg/models"
+	"soko/pkg/portage/utils"
+
+	"github.com/go-pg/pg/v10/orm"
+)
+
+const (
+	archListFile   = "profiles/arch.list"
+	archesDescFile = "profiles/arches.desc"
+)
+
+// UpdateArches reimports the arches in case profiles/arch.list
+// or profiles/arches.desc is among the given changed files
+func UpdateArches(db orm.DB, paths []string) error {
+	changed := false
+	for _, path := range paths {
+		status, changedFile, twoParts := strings.Cut(path, "\t")
+		if !twoParts {
+			changedFile = path
+		} else if status == "D" {
+			continue
+		}
+		if changedFile == archListFile || changedFile == archesDescFile {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	archList, err := utils.ReadLines(config.PortDir() + "/" + archListFile)
+	if err != nil {
+		slog.Error("Failed reading arch list", slog.Any("err", err))
+		return err
+	}
+	// arches.desc is optional, all arches are treated as unknown without it
+	archesDesc, err := utils.ReadLines(config.PortDir() + "/" + archesDescFile)
+	if err != nil {
+		slog.Warn("Failed reading arches description", slog.Any("err", err))
+	}
+
+	arches := parseArches(archList, archesDesc)
+	if len(arches) == 0 {
+		return nil
+	}
+
+	_, err = db.Model((*models.Arch)(nil)).Where("TRUE").Delete()
+	if err != nil {
+		slog.Error("Failed deleting arches", slog.Any("err", err))
+		return err
+	}
+	res, err := db.Model(&arches).Insert()
+	if err != nil {
+		slog.Error("Failed updating arches", slog.Any("err", err))
+		return err
+	}
+	slog.Info("Updated arches", slog.Int("rows", res.RowsAffected()))
+	return nil
+}
+
+// parseArches creates the arches listed in the lines of arch.list,
+// with the status taken from the lines of arches.desc
+func parseArches(archList, archesDesc []string) []*models.Arch {
+	statuses := make(map[string]string, len(archesDesc))
+	for _, line := range archesDesc {
+		line, _, _ = strings.Cut(line, "#")
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			statuses[fields[0]] = fields[1]
+		}
+	}
+
+	var arches []*models.Arch
+	seen := make(map[string]bool, len(archList))
+	for _, line := range archList {
+		line, _, _ = strings.Cut(line, "#")
+		name := strings.TrimSpace(line)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		arches = append(arches, &models.Arch{
+			Name:     name,
+			Status:   statuses[name],
+			Position: len(arches),
+		})
+	}
+	return arches
+}
@@ -0,0 +1,40 @@
+// SPDX-License-Identifier: GPL-2.0-only
+package repository
+
+import (
+	"reflect"
+	"testing"
+
+	"soko/pkg/models"
+)
+
+func TestParseArches(t *testing.T) {
+	archList := []string{
+		"# comment",
+		"alpha",
+		"amd64",
+		"",
+		"m68k # trailing comment",
+		"amd64",
+		"# Prefix keywords",
+		"x64-macos",
+	}
+	archesDesc := []string{
+		"# <keyword> <stability>",
+		"alpha testing",
+		"amd64\tstable",
+		"m68k transitional",
+		"invalid",
+	}
+
+	expected := []*models.Arch{
+		{Name: "alpha", Status: models.ArchTesting, Position: 0},
+		{Name: "amd64", Status: models.ArchStable, Position: 1},
+		{Name: "m68k", Status: models.ArchTransitional, Position: 2},
+		{Name: "x64-macos", Status: "", Position: 3},
+	}
+	got := parseArches(archList, archesDesc)
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+}
@@ -10,9 +10,10 @@ import (
 	"os"
 	"regexp"
 	"soko/pkg/config"
-	"soko/pkg/database"
 	"soko/pkg/models"
 	"strings"
+
+	"github.com/go-pg/pg/v10/orm"
 )
 
 // isCategory checks whether the path points to a category
@@ -24,7 +25,7 @@ func isCategory(path string) bool {
 
 // UpdateCategories updates the categories in the database for each
 // given path that points to a category description
-func UpdateCategories(paths []string) {
+func UpdateCategories(db orm.DB, paths []string) error {
 	deleted := map[string]*models.Category{}
 	modified := map[string]*models.Category{}
 
@@ -62,12 +63,12 @@ func UpdateCategories(paths []string) {
 		for _, row := range deleted {
 			rows = append(rows, row)
 		}
-		res, err := database.DBCon.Model(&rows).Delete()
+		res, err := db.Model(&rows).Delete()
 		if err != nil {
 			slog.Error("Failed deleting categories", slog.Any("err", err))
-		} else {
-			slog.Info("Deleted categories", slog.Int("rows", res.RowsAffected()))
+			return err
 		}
+		slog.Info("Deleted categories", slog.Int("rows", res.RowsAffected()))
 	}
 
 	if len(modified) > 0 {
@@ -75,13 +76,14 @@ func UpdateCategories(paths []string) {
 		for _, row := range modified {
 			rows = append(rows, row)
 		}
-		res, err := database.DBCon.Model(&rows).OnConflict("(name) DO UPDATE").Insert()
+		res, err := db.Model(&rows).OnConflict("(name) DO UPDATE").Insert()
 		if err != nil {
 			slog.Error("Failed updating categories", slog.Any("err", err))
-		} else {
-			slog.Info("Updated categories", slog.Int("rows", res.RowsAffected()))
+			return err
 		}
+		slog.Info("Updated categories", slog.Int("rows", res.RowsAffected()))
 	}
+	return nil
 }
 
 // updateDeletedCategory deletes a category from the database
@@ -6,14 +6,12 @@ package repository
 
 import (
 	"log/slog"
-	"os/exec"
-	"slices"
-	"soko/pkg/config"
-	"soko/pkg/database"
 	"soko/pkg/models"
 	"soko/pkg/portage/utils"
 	"strings"
 	"time"
+
+	"github.com/go-pg/pg/v10/orm"
 )
 
 var (
@@ -23,28 +21,48 @@ var (
 	keywordChanges  = map[string]*models.KeywordChange{}
 	packagesCommit  []*models.CommitToPackage
 	versionsCommits []*models.CommitToVersion
+	packageMoves    []*models.PkgMove
+
+	// knownPkgMoves maps the source to the destination of all
+	// package moves listed in profiles/updates
+	knownPkgMoves map[string]string
 )
 
-// UpdateCommits incrementally imports all new commits. New commits are
-// determined by retrieving the last commit in the database (if present)
-// and parsing all following commits. In case no last commit is present
-// a full import starting with the first commit in the tree is done.
-func UpdateCommits() string {
+// UpdateCommits incrementally imports all new commits up to the given
+// endCommit. New commits are determined by retrieving the last commit in
+// the database (if present) and parsing all following commits. In case no
+// last commit is present a full import starting with the first commit in
+// the tree is done. All queries are done using the given handle.
+func UpdateCommits(db orm.DB, endCommit string) (string, error) {
 	slog.Info("Start updating commits")
 
-	latestCommit, precedingCommitsOffset := utils.GetLatestCommitAndPreceding()
+	latestCommit, precedingCommitsOffset, err := utils.GetLatestCommitAndPreceding(db)
+	if err != nil {
+		slog.Error("Failed fetching the latest commit", slog.Any("err", err))
+		return "", err
+	}
+	knownPkgMoves, err = loadPkgMoves(db)
+	if err != nil {
+		return "", err
+	}
+	stopKeywordWorkers := startKeywordWorkers()
+	defer stopKeywordWorkers()
 
-	for precedingCommits, rawCommit := range utils.GetCommits(latestCommit, "HEAD") {
+	for precedingCommits, rawCommit := range utils.GetCommits(latestCommit, endCommit) {
 		latestCommit = processCommit(precedingCommits, precedingCommitsOffset, rawCommit)
 
 		if len(commits) > 10000 {
-			dumpToDatabase()
+			if err := dumpToDatabase(db); err != nil {
+				return "", err
+			}
 		}
 	}
-	dumpToDatabase()
+	if err := dumpToDatabase(db); err != nil {
+		return "", err
+	}
 	slog.Info("Finished updating commits")
 
-	return latestCommit
+	return latestCommit, nil
 }
 
 // processCommit parses a single commit log output and updates it into the database
@@ -92,7 +110,9 @@ func processCommit(precedingCommits, precedingCommitsOffset int, rawCommit strin
 // processChangedFiles parses files that have changed in the commit and links the
 // commit to packages and package versions
 func processChangedFiles(precedingCommits, precedingCommitsOffset int, commitLines []string, id string) *models.ChangedFiles {
-	var addedFiles, modifiedFiles, deletedFiles []*models.ChangedFile
+	var addedFiles, modifiedFiles, deletedFiles, renamedFiles []*models.ChangedFile
+	addedPackages := map[string]struct{}{}
+	deletedPackages := map[string]struct{}{}
 
 	for _, commitLine := range commitLines {
 		line := strings.Split(commitLine, "\t")
@@ -101,28 +121,64 @@ func processChangedFiles(precedingCommits, precedingCommitsOffset int, commitLin
 		}
 
 		status := strings.TrimSpace(line[0])
-		path := strings.TrimSpace(line[1])
+		path := strings.TrimSpace(line[len(line)-1])
 
 		switch {
 		case strings.HasPrefix(status, "M"):
 			modifiedFiles = append(modifiedFiles, &models.ChangedFile{Path: path, ChangeType: "M"})
 			createKeywordChange(id, path, commitLine)
-		case strings.HasPrefix(commitLine, "D"):
+		case strings.HasPrefix(status, "D"):
 			deletedFiles = append(deletedFiles, &models.ChangedFile{Path: path, ChangeType: "D"})
-		case strings.HasPrefix(commitLine, "A"):
+			if atom, ok := packageOfMetadata(path); ok {
+				deletedPackages[atom] = struct{}{}
+			}
+		case strings.HasPrefix(status, "A"):
 			addedFiles = append(addedFiles, &models.ChangedFile{Path: path, ChangeType: "A"})
 			updateFirstCommitOfPackage(path, commitLine, precedingCommitsOffset+precedingCommits+1)
 			createAddedKeywords(id, path, commitLine)
+			if atom, ok := packageOfMetadata(path); ok {
+				addedPackages[atom] = struct{}{}
+			}
+		case strings.HasPrefix(status, "R") && len(line) == 3:
+			oldPath := strings.TrimSpace(line[1])
+			renamedFiles = append(renamedFiles, &models.ChangedFile{Path: path, OldPath: oldPath, ChangeType: "R"})
+			// the keywords are compared to the keywords of the old path,
+			// so that moving a package doesn't add all of its keywords
+			createMovedKeywords(id, oldPath, path)
+			if source, ok := packageOfMetadata(oldPath); ok {
+				if destination, ok := packageOfMetadata(path); ok && source != destination {
+					packageMoves = append(packageMoves, &models.PkgMove{Source: source, Destination: destination})
+				}
+			}
+			linkCommitToPackage(oldPath, id)
+			linkCommitToVersion(oldPath, id)
+		case strings.HasPrefix(status, "C") && len(line) == 3:
+			addedFiles = append(addedFiles, &models.ChangedFile{Path: path, OldPath: strings.TrimSpace(line[1]), ChangeType: "C"})
+			updateFirstCommitOfPackage(path, "A\t"+path, precedingCommitsOffset+precedingCommits+1)
+			createAddedKeywords(id, path, "A\t"+path)
+		default:
+			continue
 		}
 
-		linkCommitToPackage(commitLine, path, id)
-		linkCommitToVersion(commitLine, path, id)
+		linkCommitToPackage(path, id)
+		linkCommitToVersion(path, id)
+	}
+
+	// packages that have been moved without git detecting the rename of
+	// the metadata.xml are correlated using the entries in profiles/updates
+	for source := range deletedPackages {
+		if destination, found := knownPkgMoves[source]; found {
+			if _, added := addedPackages[destination]; added {
+				packageMoves = append(packageMoves, &models.PkgMove{Source: source, Destination: destination})
+			}
+		}
 	}
 
 	return &models.ChangedFiles{
 		Added:    addedFiles,
 		Modified: modifiedFiles,
 		Deleted:  deletedFiles,
+		Renamed:  renamedFiles,
 	}
 }
 
@@ -136,122 +192,42 @@ func logProgress(counter int) {
 	}
 }
 
-func linkCommitToPackage(commitLine, path, id string) {
-	if (len(strings.Split(commitLine, "/")) >= 3) &&
-		(strings.HasPrefix(commitLine, "M") ||
-			strings.HasPrefix(commitLine, "D") ||
-			strings.HasPrefix(commitLine, "A")) {
-
-		pathParts := strings.Split(strings.TrimSuffix(path, ".ebuild"), "/")
-
-		packageAtom := pathParts[0] + "/" + strings.Split(commitLine, "/")[1]
-		packagesCommit = append(packagesCommit, &models.CommitToPackage{
-			Id:          id + "-" + packageAtom,
-			CommitId:    id,
-			PackageAtom: packageAtom,
-		})
+// packageOfMetadata returns the atom of the package in case
+// the given path points to the metadata.xml of a package
+func packageOfMetadata(path string) (string, bool) {
+	pathParts := strings.Split(path, "/")
+	if len(pathParts) != 3 || pathParts[2] != "metadata.xml" {
+		return "", false
 	}
+	return pathParts[0] + "/" + pathParts[1], true
 }
 
-func linkCommitToVersion(commitLine, path, id string) {
-	if (strings.HasPrefix(commitLine, "M") ||
-		strings.HasPrefix(commitLine, "D") ||
-		strings.HasPrefix(commitLine, "A")) &&
-		len(strings.Split(strings.TrimSuffix(path, ".ebuild"), "/")) == 3 &&
-		strings.HasSuffix(strings.TrimSpace(strings.Split(commitLine, "\t")[1]), ".ebuild") {
-
-		pathParts := strings.Split(strings.TrimSuffix(path, ".ebuild"), "/")
-
-		versionId := pathParts[0] + "/" + pathParts[2]
-		versionsCommits = append(versionsCommits, &models.CommitToVersion{
-			Id:        id + "-" + versionId,
-			CommitId:  id,
-			VersionId: versionId,
-		})
-	}
-}
-
-func createKeywordChange(id, path, commitLine string) {
-	if !strings.HasSuffix(path, ".ebuild") || strings.Count(commitLine, "/") < 2 {
+func linkCommitToPackage(path, id string) {
+	pathParts := strings.Split(path, "/")
+	if len(pathParts) < 3 {
 		return
 	}
 
-	raw_lines, err := utils.Exec(config.PortDir(), "git", "show", id, "--", path)
-	if err != nil {
-		if exitError, ok := err.(*exec.ExitError); !ok || exitError.ExitCode() != 1 {
-			slog.Error("Failed running git show", slog.String("id", id), slog.String("path", path), slog.Any("err", err))
-			return
-		}
-	}
-
-	var keywords_old, keywords_new []string
-
-	for _, line := range raw_lines {
-		if after, ok := strings.CutPrefix(line, "-KEYWORDS="); ok {
-			keywords_old = strings.Split(strings.ReplaceAll(after, "\"", ""), " ")
-		} else if after, ok := strings.CutPrefix(line, "+KEYWORDS="); ok {
-			keywords_new = strings.Split(strings.ReplaceAll(after, "\"", ""), " ")
-		}
-	}
-
-	var added_keywords, stabilized_keywords []string
-
-	if keywords_old != nil && keywords_new != nil {
-		for _, keyword := range keywords_new {
-			if !slices.Contains(keywords_old, keyword) {
-				added_keywords = append(added_keywords, keyword)
-			}
-
-			if !strings.HasPrefix(keyword, "~") && slices.Contains(keywords_old, "~"+keyword) {
-				stabilized_keywords = append(stabilized_keywords, keyword)
-			}
-		}
-
-		pathParts := strings.Split(strings.TrimSuffix(path, ".ebuild"), "/")
-
-		keywordChangeId := id + "-" + strings.TrimSpace(strings.Split(commitLine, "\t")[1])
-		keywordChanges[keywordChangeId] = &models.KeywordChange{
-			Id:         keywordChangeId,
-			CommitId:   id,
-			VersionId:  pathParts[0] + "/" + pathParts[2],
-			PackageId:  pathParts[0] + "/" + strings.Split(commitLine, "/")[1],
-			Added:      added_keywords,
-			Stabilized: stabilized_keywords,
-			All:        keywords_new,
-		}
-	}
+	packageAtom := pathParts[0] + "/" + pathParts[1]
+	packagesCommit = append(packagesCommit, &models.CommitToPackage{
+		Id:          id + "-" + packageAtom,
+		CommitId:    id,
+		PackageAtom: packageAtom,
+	})
 }
 
-func createAddedKeywords(id string, path string, commitLine string) {
-	if strings.HasSuffix(strings.TrimSpace(strings.Split(commitLine, "\t")[1]), ".ebuild") &&
-		(strings.Count(commitLine, "/") >= 2) {
-
-		raw_lines, err := utils.Exec(config.PortDir(), "git", "show", id, "--", path)
-		if err != nil {
-			if exitError, ok := err.(*exec.ExitError); !ok || exitError.ExitCode() != 1 {
-				slog.Error("Failed running git show", slog.String("id", id), slog.String("path", path), slog.Any("err", err))
-				return
-			}
-		}
-
-		for _, line := range raw_lines {
-			if strings.HasPrefix(line, "+KEYWORDS=") {
-				pathParts := strings.Split(strings.TrimSuffix(path, ".ebuild"), "/")
-				keywords := strings.Split(strings.ReplaceAll(strings.TrimPrefix(line, "+KEYWORDS="), "\"", ""), " ")
-
-				keywordChangeId := id + "-" + strings.TrimSpace(strings.Split(commitLine, "\t")[1])
-				keywordChanges[keywordChangeId] = &models.KeywordChange{
-					Id:        keywordChangeId,
-					CommitId:  id,
-					VersionId: pathParts[0] + "/" + pathParts[2],
-					PackageId: pathParts[0] + "/" + strings.Split(commitLine, "/")[1],
-					Added:     keywords,
-					All:       keywords,
-				}
-			}
-		}
-
+func linkCommitToVersion(path, id string) {
+	pathParts := strings.Split(strings.TrimSuffix(path, ".ebuild"), "/")
+	if len(pathParts) != 3 || !strings.HasSuffix(path, ".ebuild") {
+		return
 	}
+
+	versionId := pathParts[0] + "/" + pathParts[2]
+	versionsCommits = append(versionsCommits, &models.CommitToVersion{
+		Id:        id + "-" + versionId,
+		CommitId:  id,
+		VersionId: versionId,
+	})
 }
 
 func updateFirstCommitOfPackage(path string, commitLine string, precedingCommits int) {
@@ -265,55 +241,139 @@ func updateFirstCommitOfPackage(path string, commitLine string, precedingCommits
 	}
 }
 
-func dumpToDatabase() {
+func dumpToDatabase(db orm.DB) error {
+	// wait until the keyword changes of all processed commits are computed
+	keywordJobsPending.Wait()
+
 	slog.Info("Writing to database",
 		slog.Int("KeywordChange", len(keywordChanges)),
 		slog.Int("Package", len(packages)),
 		slog.Int("CommitToPackage", len(packagesCommit)),
 		slog.Int("CommitToVersion", len(versionsCommits)),
-		slog.Int("Commit", len(commits)))
+		slog.Int("Commit", len(commits)),
+		slog.Int("PkgMove", len(packageMoves)))
 
 	if len(keywordChanges) > 0 {
 		rows := make([]*models.KeywordChange, 0, len(keywordChanges))
 		for _, keywordChange := range keywordChanges {
 			rows = append(rows, keywordChange)
 		}
-		_, err := database.DBCon.Model(&rows).OnConflict("(id) DO UPDATE").Insert()
+		_, err := db.Model(&rows).OnConflict("(id) DO UPDATE").Insert()
 		if err != nil {
 			slog.Error("Failed inserting KeywordChange", slog.Any("err", err))
+			return err
 		}
 		clear(keywordChanges)
 	}
 
 	if len(packages) > 0 {
-		_, err := database.DBCon.Model(&packages).Column("preceding_commits").Update()
+		_, err := db.Model(&packages).Column("preceding_commits").Update()
 		if err != nil {
 			slog.Error("Failed inserting Package", slog.Any("err", err))
+			return err
 		}
 		packages = packages[:0]
 	}
 
 	if len(packagesCommit) > 0 {
-		_, err := database.DBCon.Model(&packagesCommit).OnConflict("(id) DO NOTHING").Insert()
+		_, err := db.Model(&packagesCommit).OnConflict("(id) DO NOTHING").Insert()
 		if err != nil {
 			slog.Error("Failed inserting CommitToPackage", slog.Any("err", err))
+			return err
 		}
 		packagesCommit = packagesCommit[:0]
 	}
 
 	if len(versionsCommits) > 0 {
-		_, err := database.DBCon.Model(&versionsCommits).OnConflict("(id) DO NOTHING").Insert()
+		_, err := db.Model(&versionsCommits).OnConflict("(id) DO NOTHING").Insert()
 		if err != nil {
 			slog.Error("Failed inserting CommitToVersion", slog.Any("err", err))
+			return err
 		}
 		versionsCommits = versionsCommits[:0]
 	}
 
 	if len(commits) > 0 {
-		_, err := database.DBCon.Model(&commits).OnConflict("(id) DO UPDATE").Insert()
+		_, err := db.Model(&commits).OnConflict("(id) DO UPDATE").Insert()
 		if err != nil {
 			slog.Error("Failed inserting Commit", slog.Any("err", err))
+			return err
 		}
 		commits = commits[:0]
 	}
+
+	for _, move := range packageMoves {
+		if err := carryOverHistory(db, move); err != nil {
+			return err
+		}
+	}
+	packageMoves = packageMoves[:0]
+	return nil
+}
+
+// loadPkgMoves returns the package moves from the database
+// as map from the source atom to the destination atom
+func loadPkgMoves(db orm.DB) (map[string]string, error) {
+	var rows []*models.PkgMove
+	err := db.Model(&rows).Select()
+	if err != nil {
+		slog.Error("Failed fetching pkg moves", slog.Any("err", err))
+		return nil, err
+	}
+
+	moves := make(map[string]string, len(rows))
+	for _, row := range rows {
+		moves[row.Source] = row.Destination
+	}
+	return moves, nil
+}
+
+// carryOverHistory links all commits of the source package of a package
+// move, as well as the commits of its versions, to the destination package.
+// Afterwards the preceding commits of the destination package are set to
+// the ones of the first commit of the source package, so that the package
+// keeps its original added date.
+func carryOverHistory(db orm.DB, move *models.PkgMove) error {
+	slog.Info("Carrying over history of moved package", slog.String("source", move.Source), slog.String("destination", move.Destination))
+
+	_, err := db.Exec(`INSERT INTO commit_to_packages (id, commit_id, package_atom)
+		SELECT commit_id || '-' || ?0, commit_id, ?0 FROM commit_to_packages WHERE package_atom = ?1
+		ON CONFLICT (id) DO NOTHING`, move.Destination, move.Source)
+	if err != nil {
+		slog.Error("Failed carrying over CommitToPackage", slog.String("source", move.Source), slog.Any("err", err))
+		return err
+	}
+
+	// version ids are '<category>/<package>-<version>', so that the
+	// version of the source package starts after the source atom
+	_, err = db.Exec(`INSERT INTO commit_to_versions (id, commit_id, version_id)
+		SELECT commit_id || '-' || ?0 || substr(version_id, ?2), commit_id, ?0 || substr(version_id, ?2)
+		FROM commit_to_versions WHERE left(version_id, ?3) = ?1 AND substr(version_id, ?2) ~ '^-[0-9]'
+		ON CONFLICT (id) DO NOTHING`, move.Destination, move.Source, len(move.Source)+1, len(move.Source))
+	if err != nil {
+		slog.Error("Failed carrying over CommitToVersion", slog.String("source", move.Source), slog.Any("err", err))
+		return err
+	}
+
+	// the ids of the keyword changes are '<commit>-<path of the ebuild>'
+	_, err = db.Exec(`INSERT INTO keyword_changes (id, commit_id, version_id, package_id, added, stabilized, destabilized, dropped, "all")
+		SELECT commit_id || '-' || ?0 || '/' || split_part(?0, '/', 2) || substr(version_id, ?2) || '.ebuild',
+			commit_id, ?0 || substr(version_id, ?2), ?0, added, stabilized, destabilized, dropped, "all"
+		FROM keyword_changes WHERE package_id = ?1 AND left(version_id, ?3) = ?1 AND substr(version_id, ?2) ~ '^-[0-9]'
+		ON CONFLICT (id) DO NOTHING`, move.Destination, move.Source, len(move.Source)+1, len(move.Source))
+	if err != nil {
+		slog.Error("Failed carrying over KeywordChange", slog.String("source", move.Source), slog.Any("err", err))
+		return err
+	}
+
+	_, err = db.Exec(`UPDATE packages SET preceding_commits = first.preceding_commits
+		FROM (SELECT MIN(commits.preceding_commits) AS preceding_commits FROM commits
+			JOIN commit_to_packages ON commits.id = commit_to_packages.commit_id
+			WHERE commit_to_packages.package_atom = ?0) AS first
+		WHERE atom = ?0 AND first.preceding_commits IS NOT NULL`, move.Destination)
+	if err != nil {
+		slog.Error("Failed updating preceding commits of moved package", slog.String("destination", move.Destination), slog.Any("err", err))
+		return err
+	}
+	return nil
 }
@@ -21,6 +21,7 @@ import (
 
 	"github.com/a-h/templ"
 	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
 )
 
 // isPackagesDeprecated checks whether the path
@@ -29,9 +30,9 @@ func isPackagesDeprecated(path string) bool {
 	return path == "profiles/package.deprecated"
 }
 
-// UpdatePackagesDeprecated updates all entries in
-// the Deprecated table in the database
-func UpdatePackagesDeprecated(path string) {
+// UpdatePackagesDeprecated updates all entries in the
+// Deprecated table in the database using the given handle
+func UpdatePackagesDeprecated(db orm.DB, path string) error {
 
 	splittedLine := strings.Split(path, "\t")
 
@@ -46,7 +47,7 @@ func UpdatePackagesDeprecated(path string) {
 		changedFile = splittedLine[0]
 	default:
 		// should not happen
-		return
+		return nil
 	}
 
 	if status != "D" && isPackagesDeprecated(changedFile) {
@@ -54,17 +55,23 @@ func UpdatePackagesDeprecated(path string) {
 
 		// delete all existing entries before parsing the file again
 		// in future we might implement a incremental version here
-		database.TruncateTable((*models.DeprecatedPackage)(nil))
+		if err := database.Truncate(db, (*models.DeprecatedPackage)(nil)); err != nil {
+			slog.Error("Failed truncating deprecated packages", slog.Any("err", err))
+			return err
+		}
 
 		for _, entry := range getDeprecatedPackages(changedFile) {
-			parsePackagesDeprecated(entry)
+			if err := parsePackagesDeprecated(db, entry); err != nil {
+				return err
+			}
 		}
 	}
+	return nil
 }
 
 // parse the package.mask entries and
 // update the DeprecatedPackage table in the database
-func parsePackagesDeprecated(entry string) {
+func parsePackagesDeprecated(db orm.DB, entry string) error {
 	packageLines := strings.Split(entry, "\n")
 	if len(packageLines) >= 3 {
 		packageLine, packageLines := packageLines[0], packageLines[1:]
@@ -99,13 +106,14 @@ func parsePackagesDeprecated(entry string) {
 				Versions:    version,
 			}
 
-			_, err := database.DBCon.Model(entry).OnConflict("(versions) DO UPDATE").Insert()
+			_, err := db.Model(entry).OnConflict("(versions) DO UPDATE").Insert()
 			if err != nil {
 				slog.Error("Failed inserting/updating package deprecated entry", slog.Any("err", err))
+				return err
 			}
 		}
 	}
-
+	return nil
 }
 
 // get all entries from the package.deprecated file
@@ -126,22 +134,28 @@ func getDeprecatedPackages(path string) []string {
 	return strings.Split(strings.Join(lines, "\n"), "\n\n")
 }
 
-// Calculate all versions that are currently
-// deprecated and update the DeprecatedToVersion Table
-func CalculateDeprecatedToVersion() {
-	database.TruncateTable((*models.DeprecatedToVersion)(nil))
+// Calculate all versions that are currently deprecated and
+// update the DeprecatedToVersion Table using the given handle
+func CalculateDeprecatedToVersion(db orm.DB) error {
+	if err := database.Truncate(db, (*models.DeprecatedToVersion)(nil)); err != nil {
+		slog.Error("Failed truncating deprecated versions", slog.Any("err", err))
+		return err
+	}
 
 	var deprecates []*models.DeprecatedPackage
-	err := database.DBCon.Model(&deprecates).Select()
+	err := db.Model(&deprecates).Select()
 	if err != nil && err != pg.ErrNoRows {
 		slog.Error("Failed to retrieve package masks. Aborting update", slog.Any("err", err))
-		return
+		return err
 	}
 
 	for _, deprecate := range deprecates {
 		versionSpecifier := deprecate.Versions
 		packageAtom := versionSpecifierToPackageAtom(versionSpecifier)
-		versions := utils.CalculateAffectedVersions(versionSpecifier, packageAtom)
+		versions, err := utils.CalculateAffectedVersions(db, versionSpecifier, packageAtom)
+		if err != nil {
+			return err
+		}
 
 		for _, version := range versions {
 			depToVersion := &models.DeprecatedToVersion{
@@ -150,10 +164,12 @@ func CalculateDeprecatedToVersion() {
 				VersionId:          version.Id,
 			}
 
-			_, err := database.DBCon.Model(depToVersion).OnConflict("(id) DO UPDATE").Insert()
+			_, err := db.Model(depToVersion).OnConflict("(id) DO UPDATE").Insert()
 			if err != nil {
 				slog.Error("Failed inserting/updating deprecated to version entry", slog.Any("err", err))
+				return err
 			}
 		}
 	}
+	return nil
 }
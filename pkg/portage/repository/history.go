@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Contains functions to record the history of versions and masks
+
+package repository
+
+import (
+	"fmt"
+	"log/slog"
+	"soko/pkg/models"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// currentVersions selects the current state of all versions
+// in the columns recorded in the version_histories table
+const currentVersions = `SELECT v.id AS version_id, v.category, v.package, v.atom, v.version,
+		v.slot, v.subslot, v.eapi, v.keywords, v.description,
+		ARRAY(SELECT mtv.mask_versions FROM mask_to_versions AS mtv WHERE mtv.version_id = v.id ORDER BY 1) AS masks
+	FROM versions AS v`
+
+// UpdateVersionHistory records the changes of the versions and masks at
+// the given commit. Rows of versions and masks that have been removed or
+// changed are closed, while rows are opened for new and changed ones.
+func UpdateVersionHistory(db orm.DB, commitId string) error {
+	index, err := commitIndex(db, commitId)
+	if err != nil {
+		return err
+	}
+
+	statements := []string{
+		`WITH present AS (` + currentVersions + `)
+		UPDATE version_histories AS h SET valid_to = ?0, valid_to_index = ?1
+		WHERE h.valid_to IS NULL AND NOT EXISTS (
+			SELECT 1 FROM present AS c WHERE c.version_id = h.version_id
+				AND c.slot IS NOT DISTINCT FROM h.slot
+				AND c.subslot IS NOT DISTINCT FROM h.subslot
+				AND c.eapi IS NOT DISTINCT FROM h.eapi
+				AND c.keywords IS NOT DISTINCT FROM h.keywords
+				AND c.description IS NOT DISTINCT FROM h.description
+				AND c.masks IS NOT DISTINCT FROM h.masks)`,
+		`WITH present AS (` + currentVersions + `)
+		INSERT INTO version_histories (version_id, category, package, atom, version,
+			slot, subslot, eapi, keywords, description, masks, valid_from, valid_from_index)
+		SELECT c.*, ?0, ?1 FROM present AS c
+		WHERE NOT EXISTS (SELECT 1 FROM version_histories AS h
+			WHERE h.valid_to IS NULL AND h.version_id = c.version_id)`,
+		`UPDATE mask_histories AS h SET valid_to = ?0, valid_to_index = ?1
+		WHERE h.valid_to IS NULL AND NOT EXISTS (
+			SELECT 1 FROM masks AS m WHERE m.versions = h.versions
+				AND m.author IS NOT DISTINCT FROM h.author
+				AND m.author_email IS NOT DISTINCT FROM h.author_email
+				AND m.date IS NOT DISTINCT FROM h.date
+				AND m.reason IS NOT DISTINCT FROM h.reason)`,
+		`INSERT INTO mask_histories (versions, author, author_email, date, reason, valid_from, valid_from_index)
+		SELECT m.versions, m.author, m.author_email, m.date, m.reason, ?0, ?1 FROM masks AS m
+		WHERE NOT EXISTS (SELECT 1 FROM mask_histories AS h
+			WHERE h.valid_to IS NULL AND h.versions = m.versions)`,
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement, commitId, index); err != nil {
+			slog.Error("Failed updating version history", slog.String("commit", commitId), slog.Any("err", err))
+			return err
+		}
+	}
+	return nil
+}
+
+// commitIndex returns the number of commits preceding the given commit.
+// In case the commit has not been imported, an error is returned, as the
+// rows can't be ordered relative to the other commits.
+func commitIndex(db orm.DB, commitId string) (int, error) {
+	commit := &models.Commit{Id: commitId}
+	err := db.Model(commit).Column("preceding_commits").WherePK().Select()
+	if err == pg.ErrNoRows {
+		return 0, fmt.Errorf("commit %s has not been imported", commitId)
+	}
+	return commit.PrecedingCommits, err
+}
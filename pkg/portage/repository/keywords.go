@@ -0,0 +1,344 @@
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Contains functions to compute the keyword changes of commits
+
+package repository
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"log/slog"
+	"runtime"
+	"slices"
+	"soko/pkg/models"
+	"soko/pkg/portage/utils"
+	"strings"
+	"sync"
+)
+
+// keywordJob describes an ebuild that has been added, modified or
+// renamed in a commit. The old path is only set for renamed ebuilds.
+type keywordJob struct {
+	commitId string
+	path     string
+	oldPath  string
+	added    bool
+}
+
+var (
+	keywordJobs        chan keywordJob
+	keywordJobsPending sync.WaitGroup
+	keywordChangesLock sync.Mutex
+)
+
+// startKeywordWorkers starts a bounded pool of workers computing the keyword
+// changes of added and modified ebuilds in-process. Each worker reads the
+// ebuilds using its own 'git cat-file --batch' process. The returned function
+// waits for all pending jobs and stops the workers.
+func startKeywordWorkers() func() {
+	keywordJobs = make(chan keywordJob, 1024)
+
+	var workers sync.WaitGroup
+	for range runtime.NumCPU() {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			runKeywordWorker()
+		}()
+	}
+
+	return func() {
+		close(keywordJobs)
+		workers.Wait()
+	}
+}
+
+// runKeywordWorker processes keyword jobs until the job channel is closed
+func runKeywordWorker() {
+	reader, err := utils.NewBlobReader()
+	if err != nil {
+		slog.Error("Failed starting git cat-file", slog.Any("err", err))
+		for range keywordJobs {
+			keywordJobsPending.Done()
+		}
+		return
+	}
+	defer reader.Close()
+
+	for job := range keywordJobs {
+		keywordChange, err := computeKeywordChange(reader, job)
+		if err != nil {
+			slog.Error("Failed computing keyword change", slog.String("id", job.commitId), slog.String("path", job.path), slog.Any("err", err))
+		} else if keywordChange != nil {
+			keywordChangesLock.Lock()
+			keywordChanges[keywordChange.Id] = keywordChange
+			keywordChangesLock.Unlock()
+		}
+		keywordJobsPending.Done()
+	}
+}
+
+// createKeywordChange enqueues the computation of the changed
+// keywords of an ebuild modified in the given commit
+func createKeywordChange(id, path, commitLine string) {
+	if !strings.HasSuffix(path, ".ebuild") || strings.Count(commitLine, "/") < 2 {
+		return
+	}
+	keywordJobsPending.Add(1)
+	keywordJobs <- keywordJob{commitId: id, path: path}
+}
+
+// createAddedKeywords enqueues the computation of the
+// keywords of an ebuild added in the given commit
+func createAddedKeywords(id string, path string, commitLine string) {
+	if !strings.HasSuffix(path, ".ebuild") || strings.Count(commitLine, "/") < 2 {
+		return
+	}
+	keywordJobsPending.Add(1)
+	keywordJobs <- keywordJob{commitId: id, path: path, added: true}
+}
+
+// createMovedKeywords enqueues the computation of the changed keywords
+// of an ebuild renamed from the given old path in the given commit
+func createMovedKeywords(id, oldPath, path string) {
+	if !strings.HasSuffix(path, ".ebuild") || strings.Count(path, "/") < 2 {
+		return
+	}
+	if !strings.HasSuffix(oldPath, ".ebuild") || strings.Count(oldPath, "/") < 2 {
+		// e.g. a file that has been renamed to an ebuild
+		createAddedKeywords(id, path, "A\t"+path)
+		return
+	}
+	keywordJobsPending.Add(1)
+	keywordJobs <- keywordJob{commitId: id, path: path, oldPath: oldPath}
+}
+
+// computeKeywordChange compares the keywords of the version before and after
+// the commit. In case the keywords didn't change or can't be determined
+// before or after the commit, nil is returned.
+func computeKeywordChange(reader *utils.BlobReader, job keywordJob) (*models.KeywordChange, error) {
+	keywordsNew, found, err := keywordsAt(reader, job.commitId, job.path)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	var keywordsOld []string
+	if !job.added {
+		oldPath := job.path
+		if job.oldPath != "" {
+			oldPath = job.oldPath
+		}
+		keywordsOld, found, err = keywordsAt(reader, job.commitId+"^", oldPath)
+		if err != nil || !found {
+			return nil, err
+		}
+	}
+
+	added, stabilized, destabilized, dropped := diffKeywords(keywordsOld, keywordsNew)
+	if len(added) == 0 && len(stabilized) == 0 && len(destabilized) == 0 && len(dropped) == 0 {
+		return nil, nil
+	}
+
+	pathParts := strings.Split(strings.TrimSuffix(job.path, ".ebuild"), "/")
+	return &models.KeywordChange{
+		Id:           job.commitId + "-" + job.path,
+		CommitId:     job.commitId,
+		VersionId:    pathParts[0] + "/" + pathParts[2],
+		PackageId:    pathParts[0] + "/" + pathParts[1],
+		Added:        added,
+		Stabilized:   stabilized,
+		Destabilized: destabilized,
+		Dropped:      dropped,
+		All:          keywordsNew,
+	}, nil
+}
+
+// keywordsAt returns the keywords of the version of the given ebuild at the
+// given revision. The keywords are taken from the md5-cache entry of the
+// version, which is only part of the history in case the remote contains the
+// md5-cache, like the sync mirror of the repository does. In case the
+// md5-cache entry is missing or has not been regenerated for this revision of
+// the ebuild, the ebuild itself is parsed instead. In case the keywords can't
+// be determined, false is returned.
+func keywordsAt(reader *utils.BlobReader, revision, path string) ([]string, bool, error) {
+	ebuild, found, err := reader.Read(revision, path)
+	if err != nil || !found {
+		return nil, false, err
+	}
+
+	pathParts := strings.Split(strings.TrimSuffix(path, ".ebuild"), "/")
+	cache, found, err := reader.Read(revision, "metadata/md5-cache/"+pathParts[0]+"/"+pathParts[2])
+	if err != nil {
+		return nil, false, err
+	}
+	if found {
+		keywords, ebuildMd5 := parseCacheEntry(cache)
+		if sum := md5.Sum(ebuild); ebuildMd5 == hex.EncodeToString(sum[:]) {
+			return keywords, true, nil
+		}
+	}
+	keywords, known := parseEbuildKeywords(ebuild)
+	return keywords, known, nil
+}
+
+// parseCacheEntry returns the keywords as well as the md5
+// of the ebuild of the given md5-cache entry
+func parseCacheEntry(content []byte) (keywords []string, ebuildMd5 string) {
+	for line := range bytes.Lines(content) {
+		text := strings.TrimRight(string(line), "\r\n")
+		if after, ok := strings.CutPrefix(text, "KEYWORDS="); ok {
+			keywords = strings.Fields(after)
+		} else if after, ok := strings.CutPrefix(text, "_md5_="); ok {
+			ebuildMd5 = after
+		}
+	}
+	return
+}
+
+// parseEbuildKeywords returns the keywords assigned in the ebuild. Assignments
+// may be indented and may span multiple lines. References to KEYWORDS itself
+// are expanded. The conditions of the ebuild aren't evaluated, so that the
+// keywords can't be determined and false is returned in case an assignment is
+// part of a conditional, e.g. the else branch of a live ebuild check or a
+// command guarded by '&&', or in case the last assignment references other
+// variables.
+func parseEbuildKeywords(content []byte) ([]string, bool) {
+	var keywords []string
+	known := true
+	depth := 0
+	lines := strings.Split(string(content), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		prefix, value, ok := cutKeywordsAssignment(line)
+		if !ok {
+			depth = blockDepth(shellWords(line), depth)
+			continue
+		}
+		if words := shellWords(prefix); blockDepth(words, depth) > 0 ||
+			(len(words) > 0 && slices.Contains(conditionalSeparators, words[len(words)-1])) {
+			return nil, false
+		}
+
+		if quote := value[:min(1, len(value))]; quote == `"` || quote == "'" {
+			value = value[1:]
+			for !strings.Contains(value, quote) && i+1 < len(lines) {
+				i++
+				value += " " + strings.TrimSpace(lines[i])
+			}
+			value, _, _ = strings.Cut(value, quote)
+		} else {
+			value, _, _ = strings.Cut(value, ";")
+			value, _, _ = strings.Cut(strings.TrimSpace(value), " ")
+		}
+
+		previous := strings.Join(keywords, " ")
+		value = strings.NewReplacer("${KEYWORDS}", previous, "$KEYWORDS", previous).Replace(value)
+		if strings.Contains(value, "$") {
+			keywords, known = nil, false
+		} else {
+			keywords, known = strings.Fields(value), true
+		}
+	}
+	return keywords, known
+}
+
+var (
+	// commandSeparators are the words, after which a command may follow
+	commandSeparators = []string{";", "&&", "||", "then", "else", "elif", "do"}
+	// conditionalSeparators are the words, after which a command is conditional
+	conditionalSeparators = []string{"&&", "||"}
+	// blockOpeners and blockClosers are the words opening and closing conditionals and loops
+	blockOpeners = []string{"if", "case", "for", "while", "until", "select"}
+	blockClosers = []string{"fi", "esac", "done"}
+)
+
+// precedesCommand reports whether a command may follow the given word,
+// that is a separator or the pattern of a case branch
+func precedesCommand(word string) bool {
+	return slices.Contains(commandSeparators, word) || strings.HasSuffix(word, ")")
+}
+
+// shellWords splits the given line into words, treating ';' as word of its own
+func shellWords(line string) []string {
+	return strings.Fields(strings.ReplaceAll(line, ";", " ; "))
+}
+
+// blockDepth returns the nesting depth of conditionals and loops after the
+// given words, starting at the given depth. Only words in command position
+// open or close a block.
+func blockDepth(words []string, depth int) int {
+	command := true
+	for _, word := range words {
+		if command {
+			if slices.Contains(blockOpeners, word) {
+				depth++
+			} else if slices.Contains(blockClosers, word) {
+				depth = max(depth-1, 0)
+			}
+		}
+		command = precedesCommand(word)
+	}
+	return depth
+}
+
+// cutKeywordsAssignment returns the words preceding an assignment to KEYWORDS
+// in the given line as well as the assigned value, in case the line contains
+// an assignment, either as a command of its own, following a separator like
+// ';', '&&' or 'then' or a case pattern, or as argument of export
+func cutKeywordsAssignment(line string) (string, string, bool) {
+	for offset := 0; ; {
+		index := strings.Index(line[offset:], "KEYWORDS=")
+		if index < 0 {
+			return "", "", false
+		}
+		index += offset
+		offset = index + len("KEYWORDS=")
+
+		// e.g. MY_KEYWORDS= doesn't assign KEYWORDS
+		if index > 0 && !strings.ContainsRune(" \t;", rune(line[index-1])) {
+			continue
+		}
+		words := shellWords(line[:index])
+		if len(words) == 0 {
+			return "", line[offset:], true
+		}
+		if last := words[len(words)-1]; last == "export" || precedesCommand(last) {
+			return line[:index], line[offset:], true
+		}
+	}
+}
+
+// diffKeywords compares the old and the new keywords of a version and returns
+//   - the added keywords, that is all new keywords which haven't been present before
+//   - the stabilized keywords, that is all stable keywords which have been testing before
+//   - the destabilized keywords, that is all testing keywords which have been stable before
+//   - the dropped keywords, that is all old keywords whose arch isn't keyworded anymore
+func diffKeywords(keywordsOld, keywordsNew []string) (added, stabilized, destabilized, dropped []string) {
+	arches := make(map[string]struct{}, len(keywordsNew))
+	for _, keyword := range keywordsNew {
+		arches[strings.TrimLeft(keyword, "~-")] = struct{}{}
+
+		if !slices.Contains(keywordsOld, keyword) {
+			added = append(added, keyword)
+		}
+
+		if !strings.HasPrefix(keyword, "~") && slices.Contains(keywordsOld, "~"+keyword) {
+			stabilized = append(stabilized, keyword)
+		}
+
+		if arch, ok := strings.CutPrefix(keyword, "~"); ok && slices.Contains(keywordsOld, arch) {
+			destabilized = append(destabilized, keyword)
+		}
+	}
+
+	for _, keyword := range keywordsOld {
+		if _, found := arches[strings.TrimLeft(keyword, "~-")]; !found {
+			dropped = append(dropped, keyword)
+		}
+	}
+	return
+}
@@ -0,0 +1,67 @@
+// SPDX-License-Identifier: GPL-2.0-only
+package repository
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseEbuildKeywords(t *testing.T) {
+	testCases := []struct {
+		name     string
+		ebuild   string
+		expected []string
+		known    bool
+	}{
+		{"simple", "EAPI=8\nKEYWORDS=\"~amd64 x86\"\n", []string{"~amd64", "x86"}, true},
+		{"single quotes", "KEYWORDS='amd64'\n", []string{"amd64"}, true},
+		{"unquoted", "KEYWORDS=amd64\n", []string{"amd64"}, true},
+		{"conditional", "if [[ ${PV} == 9999* ]]; then\n\tinherit git-r3\nelse\n\tKEYWORDS=\"~arm64 ~riscv\"\nfi\n", nil, false},
+		{"live branch", "if [[ ${PV} == 9999* ]]; then\n\tKEYWORDS=\"\"\nelse\n\tKEYWORDS=\"amd64 ~ppc\"\nfi\n", nil, false},
+		{"one line conditional", "[[ ${PV} != 9999* ]] && KEYWORDS=\"~amd64\"\n", nil, false},
+		{"case", "case ${PV} in\n\t9999) ;;\n\t*) KEYWORDS=\"~amd64\" ;;\nesac\n", nil, false},
+		{"after conditional", "if [[ ${PV} == 9999 ]]; then\n\tinherit git-r3\nfi\nKEYWORDS=\"~amd64\"\n", []string{"~amd64"}, true},
+		{"exported", "export KEYWORDS=\"~amd64\"\n", []string{"~amd64"}, true},
+		{"emptied", "KEYWORDS=\"~amd64\"\nKEYWORDS=\"\"\n", nil, true},
+		{"multi line", "KEYWORDS=\"~alpha amd64\n\t~arm ~arm64\n\tx86\"\nIUSE=\"test\"\n", []string{"~alpha", "amd64", "~arm", "~arm64", "x86"}, true},
+		{"variable", "KEYWORDS=\"~amd64\"\nKEYWORDS=\"${KEYWORDS} ~x86\"\n", []string{"~amd64", "~x86"}, true},
+		{"one line if", "if [[ ${PV} == 9999 ]]; then inherit git-r3; else KEYWORDS=\"~amd64\"; fi\n", nil, false},
+		{"other variable", "KEYWORDS=\"${MY_KEYWORDS}\"\n", nil, false},
+		{"prefixed variable", "MY_KEYWORDS=\"amd64\"\nKEYWORDS=\"~amd64\"\n", []string{"~amd64"}, true},
+		{"comment", "KEYWORDS=\"~amd64\"\n# KEYWORDS=\"amd64\"\n", []string{"~amd64"}, true},
+		{"none", "EAPI=8\n", nil, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, known := parseEbuildKeywords([]byte(tc.ebuild))
+			if !slices.Equal(got, tc.expected) || known != tc.known {
+				t.Errorf("Expected %q, %t, got %q, %t", tc.expected, tc.known, got, known)
+			}
+		})
+	}
+}
+
+func TestDiffKeywords(t *testing.T) {
+	testCases := []struct {
+		name                                     string
+		old, new                                 []string
+		added, stabilized, destabilized, dropped []string
+	}{
+		{"new version", nil, []string{"~amd64", "~x86"}, []string{"~amd64", "~x86"}, nil, nil, nil},
+		{"keyworded", []string{"~amd64"}, []string{"~amd64", "~arm64"}, []string{"~arm64"}, nil, nil, nil},
+		{"stabilized", []string{"~amd64", "~x86"}, []string{"amd64", "~x86"}, []string{"amd64"}, []string{"amd64"}, nil, nil},
+		{"destabilized", []string{"amd64", "~mips", "x86"}, []string{"amd64", "~x86"}, []string{"~x86"}, nil, []string{"~x86"}, []string{"~mips"}},
+		{"dropped", []string{"amd64", "ppc"}, []string{"amd64"}, nil, nil, nil, []string{"ppc"}},
+		{"unchanged", []string{"amd64", "~x86"}, []string{"~x86", "amd64"}, nil, nil, nil, nil},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			added, stabilized, destabilized, dropped := diffKeywords(tc.old, tc.new)
+			if !slices.Equal(added, tc.added) || !slices.Equal(stabilized, tc.stabilized) ||
+				!slices.Equal(destabilized, tc.destabilized) || !slices.Equal(dropped, tc.dropped) {
+				t.Errorf("Expected %q, %q, %q, %q, got %q, %q, %q, %q", tc.added, tc.stabilized, tc.destabilized, tc.dropped,
+					added, stabilized, destabilized, dropped)
+			}
+		})
+	}
+}
@@ -25,6 +25,7 @@ import (
 
 	"github.com/a-h/templ"
 	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
 )
 
 // isMask checks whether the path
@@ -33,9 +34,9 @@ func isMask(path string) bool {
 	return path == "profiles/package.mask"
 }
 
-// UpdateMask updates all entries in
-// the Mask table in the database
-func UpdateMask(path string) {
+// UpdateMask updates all entries in the Mask
+// table in the database using the given handle
+func UpdateMask(db orm.DB, path string) error {
 
 	splittedLine := strings.Split(path, "\t")
 
@@ -50,7 +51,7 @@ func UpdateMask(path string) {
 		changedFile = splittedLine[0]
 	default:
 		// should not happen
-		return
+		return nil
 	}
 
 	if status != "D" && isMask(changedFile) {
@@ -58,12 +59,18 @@ func UpdateMask(path string) {
 
 		// delete all existing masks before parsing the file again
 		// in future we might implement a incremental version here
-		database.TruncateTable((*models.Mask)(nil))
+		if err := database.Truncate(db, (*models.Mask)(nil)); err != nil {
+			slog.Error("Failed truncating package masks", slog.Any("err", err))
+			return err
+		}
 
 		for _, packageMask := range getMasks(changedFile) {
-			parsePackageMask(packageMask)
+			if err := parsePackageMask(db, packageMask); err != nil {
+				return err
+			}
 		}
 	}
+	return nil
 }
 
 var versionNumber = regexp.MustCompile(`-[0-9]`)
@@ -108,7 +115,7 @@ var bugReplacer = regexp.MustCompile(`#(\d+)`)
 
 // parse the package.mask entries and
 // update the Mask table in the database
-func parsePackageMask(packageMask string) {
+func parsePackageMask(db orm.DB, packageMask string) error {
 	packageMaskLines := strings.Split(packageMask, "\n")
 	if len(packageMaskLines) >= 3 {
 		packageMaskLine, packageMaskLines := packageMaskLines[0], packageMaskLines[1:]
@@ -143,13 +150,14 @@ func parsePackageMask(packageMask string) {
 				Versions:    version,
 			}
 
-			_, err := database.DBCon.Model(mask).OnConflict("(versions) DO UPDATE").Insert()
+			_, err := db.Model(mask).OnConflict("(versions) DO UPDATE").Insert()
 			if err != nil {
 				slog.Error("Failed inserting/updating package mask entry", slog.Any("err", err))
+				return err
 			}
 		}
 	}
-
+	return nil
 }
 
 // get all mask entries from the package.mask file
@@ -171,29 +179,38 @@ func getMasks(path string) []string {
 	return strings.Split(strings.Join(lines, "\n"), "\n\n")
 }
 
-// Calculate all versions that are currently
-// masked and update the MaskToVersion Table
-func CalculateMaskedVersions() {
+// Calculate all versions that are currently masked and
+// update the MaskToVersion Table using the given handle
+func CalculateMaskedVersions(db orm.DB) error {
 	// clean up all masked versions before recalculating them
-	database.TruncateTable((*models.MaskToVersion)(nil))
+	if err := database.Truncate(db, (*models.MaskToVersion)(nil)); err != nil {
+		slog.Error("Failed truncating masked versions", slog.Any("err", err))
+		return err
+	}
 
 	var masks []*models.Mask
-	err := database.DBCon.Model(&masks).Select()
+	err := db.Model(&masks).Select()
 	if err != nil && err != pg.ErrNoRows {
 		slog.Error("Failed to retrieve package masks. Aborting update", slog.Any("err", err))
-		return
+		return err
 	}
 
 	for _, mask := range masks {
 		versionSpecifier := mask.Versions
 		packageAtom := versionSpecifierToPackageAtom(versionSpecifier)
-		versions := utils.CalculateAffectedVersions(versionSpecifier, packageAtom)
-		maskVersions(versionSpecifier, versions)
+		versions, err := utils.CalculateAffectedVersions(db, versionSpecifier, packageAtom)
+		if err != nil {
+			return err
+		}
+		if err := maskVersions(db, versionSpecifier, versions); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 // maskVersions updates the MaskToVersion table using the given versions
-func maskVersions(versionSpecifier string, versions []*models.Version) {
+func maskVersions(db orm.DB, versionSpecifier string, versions []*models.Version) error {
 	for _, version := range versions {
 		maskToVersion := &models.MaskToVersion{
 			Id:           versionSpecifier + "-" + version.Id,
@@ -201,10 +218,12 @@ func maskVersions(versionSpecifier string, versions []*models.Version) {
 			VersionId:    version.Id,
 		}
 
-		_, err := database.DBCon.Model(maskToVersion).OnConflict("(id) DO UPDATE").Insert()
+		_, err := db.Model(maskToVersion).OnConflict("(id) DO UPDATE").Insert()
 
 		if err != nil {
 			slog.Error("Error while inserting mask to version entry", slog.Any("err", err))
+			return err
 		}
 	}
+	return nil
 }
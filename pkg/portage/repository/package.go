@@ -10,10 +10,11 @@ import (
 	"os"
 	"regexp"
 	"soko/pkg/config"
-	"soko/pkg/database"
 	"soko/pkg/models"
 	"soko/pkg/utils"
 	"strings"
+
+	"github.com/go-pg/pg/v10/orm"
 )
 
 // isPackage checks whether the path points to a package
@@ -25,7 +26,7 @@ func isPackage(path string) bool {
 
 // UpdatePackages updates the packages in the database for each
 // given path that points to a package description
-func UpdatePackages(paths []string) {
+func UpdatePackages(db orm.DB, paths []string) error {
 	deleted := map[string]*models.Package{}
 	modified := map[string]*models.Package{}
 
@@ -64,12 +65,12 @@ func UpdatePackages(paths []string) {
 		for _, row := range deleted {
 			rows = append(rows, row)
 		}
-		res, err := database.DBCon.Model(&rows).Delete()
+		res, err := db.Model(&rows).Delete()
 		if err != nil {
 			slog.Error("Failed deleting packages", slog.Any("err", err))
-		} else {
-			slog.Info("Deleted packages", slog.Int("rows", res.RowsAffected()))
+			return err
 		}
+		slog.Info("Deleted packages", slog.Int("rows", res.RowsAffected()))
 	}
 
 	if len(modified) > 0 {
@@ -77,7 +78,7 @@ func UpdatePackages(paths []string) {
 		for _, row := range modified {
 			rows = append(rows, row)
 		}
-		res, err := database.DBCon.Model(&rows).OnConflict("(atom) DO UPDATE").
+		res, err := db.Model(&rows).OnConflict("(atom) DO UPDATE").
 			Set("atom = EXCLUDED.atom").
 			Set("category = EXCLUDED.category").
 			Set("name = EXCLUDED.name").
@@ -87,10 +88,11 @@ func UpdatePackages(paths []string) {
 			Insert()
 		if err != nil {
 			slog.Error("Failed updating packages", slog.Any("err", err))
-		} else {
-			slog.Info("Updated packages", slog.Int("rows", res.RowsAffected()))
+			return err
 		}
+		slog.Info("Updated packages", slog.Int("rows", res.RowsAffected()))
 	}
+	return nil
 }
 
 // updateDeletedPackage deletes a package from the database
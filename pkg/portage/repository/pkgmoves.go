@@ -6,12 +6,13 @@ import (
 	"strings"
 
 	"soko/pkg/config"
-	"soko/pkg/database"
 	"soko/pkg/models"
 	"soko/pkg/portage/utils"
+
+	"github.com/go-pg/pg/v10/orm"
 )
 
-func UpdatePkgMoves(paths []string) {
+func UpdatePkgMoves(db orm.DB, paths []string) error {
 	pkgMoves := make(map[string]*models.PkgMove)
 
 	for _, path := range paths {
@@ -47,11 +48,12 @@ func UpdatePkgMoves(paths []string) {
 		for _, row := range pkgMoves {
 			rows = append(rows, row)
 		}
-		res, err := database.DBCon.Model(&rows).OnConflict("(source) DO UPDATE").Insert()
+		res, err := db.Model(&rows).OnConflict("(source) DO UPDATE").Insert()
 		if err != nil {
 			slog.Error("Failed updating pkg moves", slog.Any("err", err))
-		} else {
-			slog.Info("Updated pkg moves", slog.Int("rows", res.RowsAffected()))
+			return err
 		}
+		slog.Info("Updated pkg moves", slog.Int("rows", res.RowsAffected()))
 	}
+	return nil
 }
@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Contains functions to build the full text search documents
+
+package repository
+
+import (
+	"log/slog"
+	"strings"
+
+	"soko/pkg/models"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// UpdateSearchDocuments rebuilds the full text search documents of
+// the packages touched by the given changed files, or of all packages
+// in case of a full update. Documents of removed packages are deleted.
+//
+// The name weighs most, followed by the descriptions of the versions,
+// the long description, and the local USE flag descriptions and
+// upstream remote-ids.
+func UpdateSearchDocuments(db orm.DB, paths []string) error {
+	atoms, all := changedAtoms(paths)
+	if !all {
+		// build all documents in case none have been built so far
+		exists, err := db.Model((*models.PackageSearch)(nil)).Exists()
+		if err != nil {
+			slog.Error("Failed checking search documents", slog.Any("err", err))
+			return err
+		}
+		all = !exists
+	}
+	if !all && len(atoms) == 0 {
+		return nil
+	}
+
+	res, err := db.Exec(`INSERT INTO package_searches (atom, document)
+		SELECT p.atom,
+			SETWEIGHT(TO_TSVECTOR('english', p.name), 'A') ||
+			SETWEIGHT(TO_TSVECTOR('english', COALESCE((
+				SELECT STRING_AGG(DISTINCT v.description, ' ') FROM versions AS v WHERE v.atom = p.atom), '')), 'B') ||
+			SETWEIGHT(TO_TSVECTOR('english', COALESCE(p.longdescription, '')), 'C') ||
+			SETWEIGHT(TO_TSVECTOR('english', COALESCE((
+				SELECT STRING_AGG(u.description, ' ') FROM useflags AS u WHERE u.package = p.atom AND u.scope = 'local'), '')), 'D') ||
+			SETWEIGHT(TO_TSVECTOR('simple', COALESCE((
+				SELECT STRING_AGG(r ->> 'Id', ' ') FROM JSONB_ARRAY_ELEMENTS(p.upstream -> 'RemoteIds') AS r), '')), 'D')
+		FROM packages AS p
+		WHERE ?0 OR p.atom = ANY(?1)
+		ON CONFLICT (atom) DO UPDATE SET document = EXCLUDED.document`, all, pg.Array(atoms))
+	if err != nil {
+		slog.Error("Failed updating search documents", slog.Any("err", err))
+		return err
+	}
+	slog.Info("Updated search documents", slog.Int("rows", res.RowsAffected()))
+
+	_, err = db.Exec("DELETE FROM package_searches WHERE atom NOT IN (SELECT atom FROM packages)")
+	if err != nil {
+		slog.Error("Failed deleting search documents", slog.Any("err", err))
+	}
+	return err
+}
+
+// changedAtoms returns the atoms of the packages containing the given
+// changed files. All is set in case the paths are the files of a full
+// update, that is paths without status.
+func changedAtoms(paths []string) (atoms []string, all bool) {
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		_, changedFile, twoParts := strings.Cut(path, "\t")
+		if !twoParts {
+			return nil, true
+		}
+		parts := strings.Split(changedFile, "/")
+		if len(parts) < 3 {
+			continue
+		}
+		atom := parts[0] + "/" + parts[1]
+		if !seen[atom] {
+			seen[atom] = true
+			atoms = append(atoms, atom)
+		}
+	}
+	return atoms, false
+}
@@ -0,0 +1,28 @@
+// SPDX-License-Identifier: GPL-2.0-only
+package repository
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestChangedAtoms(t *testing.T) {
+	testCases := []struct {
+		name     string
+		paths    []string
+		expected []string
+		all      bool
+	}{
+		{"incremental", []string{"M\tapp-misc/foo/foo-1.ebuild", "A\tapp-misc/foo/metadata.xml", "D\tdev-libs/bar/bar-2.ebuild", ""}, []string{"app-misc/foo", "dev-libs/bar"}, false},
+		{"no packages", []string{"M\tprofiles/use.desc", "M\tmetadata/layout.conf"}, nil, false},
+		{"full update", []string{"app-misc/foo/foo-1.ebuild", "profiles/use.desc"}, nil, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			atoms, all := changedAtoms(tc.paths)
+			if all != tc.all || !slices.Equal(atoms, tc.expected) {
+				t.Errorf("Expected %q (all %v), got %q (all %v)", tc.expected, tc.all, atoms, all)
+			}
+		})
+	}
+}
@@ -7,16 +7,17 @@ package repository
 import (
 	"log/slog"
 	"soko/pkg/config"
-	"soko/pkg/database"
 	"soko/pkg/models"
 	"soko/pkg/portage/utils"
 	"strings"
+
+	"github.com/go-pg/pg/v10/orm"
 )
 
 // UpdateUse reads all USE flags descriptions from the given file in
 // case the given file contains USE flags descriptions and imports
-// each USE flag into the database
-func UpdateUse(path string) {
+// each USE flag into the database using the given handle
+func UpdateUse(db orm.DB, path string) error {
 	splittedLine := strings.Split(path, "\t")
 
 	var status, changedFile string
@@ -30,7 +31,7 @@ func UpdateUse(path string) {
 		changedFile = splittedLine[0]
 	default:
 		// should not happen
-		return
+		return nil
 	}
 
 	if status != "D" && (isLocalUseflag(changedFile) || isGlobalUseflag(changedFile) || isUseExpand(changedFile)) {
@@ -60,15 +61,15 @@ func UpdateUse(path string) {
 			for _, row := range useFlags {
 				rows = append(rows, row)
 			}
-			res, err := database.DBCon.Model(&rows).OnConflict("(id) DO UPDATE").Insert()
+			res, err := db.Model(&rows).OnConflict("(id) DO UPDATE").Insert()
 			if err != nil {
 				slog.Error("Failed updating use flags", slog.Any("err", err))
-			} else {
-				slog.Info("Updated use flags", slog.Int("rows", res.RowsAffected()))
+				return err
 			}
+			slog.Info("Updated use flags", slog.Int("rows", res.RowsAffected()))
 		}
 	}
-
+	return nil
 }
 
 // createUseflag parses the description from the file,
@@ -8,10 +8,11 @@ import (
 	"log/slog"
 	"regexp"
 	"soko/pkg/config"
-	"soko/pkg/database"
 	"soko/pkg/models"
 	"soko/pkg/portage/utils"
 	"strings"
+
+	"github.com/go-pg/pg/v10/orm"
 )
 
 // isVersion checks whether the path points to a package version
@@ -23,7 +24,7 @@ func isVersion(path string) bool {
 
 // UpdateVersions updates the versions in the database for each
 // given path that points to a package version
-func UpdateVersions(paths []string) {
+func UpdateVersions(db orm.DB, paths []string) error {
 	deleted := map[string]*models.Version{}
 	modified := map[string]*models.Version{}
 
@@ -60,12 +61,12 @@ func UpdateVersions(paths []string) {
 		for _, row := range deleted {
 			rows = append(rows, row)
 		}
-		res, err := database.DBCon.Model(&rows).Delete()
+		res, err := db.Model(&rows).Delete()
 		if err != nil {
 			slog.Error("Failed deleting versions", slog.Any("err", err))
-		} else {
-			slog.Info("Deleted versions", slog.Int("rows", res.RowsAffected()))
+			return err
 		}
+		slog.Info("Deleted versions", slog.Int("rows", res.RowsAffected()))
 	}
 
 	if len(modified) > 0 {
@@ -73,13 +74,14 @@ func UpdateVersions(paths []string) {
 		for _, row := range modified {
 			rows = append(rows, row)
 		}
-		res, err := database.DBCon.Model(&rows).OnConflict("(id) DO UPDATE").Insert()
+		res, err := db.Model(&rows).OnConflict("(id) DO UPDATE").Insert()
 		if err != nil {
 			slog.Error("Failed updating versions", slog.Any("err", err))
-		} else {
-			slog.Info("Updated versions", slog.Int("rows", res.RowsAffected()))
+			return err
 		}
+		slog.Info("Updated versions", slog.Int("rows", res.RowsAffected()))
 	}
+	return nil
 }
 
 // updateDeletedVersion deletes a package version from the database
@@ -5,39 +5,180 @@
 package portage
 
 import (
+	"fmt"
 	"log/slog"
 	"os"
 	"soko/pkg/config"
 	"soko/pkg/database"
 	"soko/pkg/models"
+	"soko/pkg/portage/maintainers"
 	"soko/pkg/portage/repository"
 	"soko/pkg/portage/utils"
 	"strings"
 	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
 )
 
 // Update incrementally updates the whole data in the database. All commits
 // since the last update are parsed and the changed data is updated. In case
 // this is the first update that is there is no last update a full import
 // starting with the first commit in the tree is done.
-func Update() {
+//
+// Each update is recorded as UpdateRun. The phases of the run are executed
+// in separate transactions, so that a failed or interrupted phase is rolled
+// back and is redone by the next update.
+func Update() error {
 	database.Connect()
 	defer database.DBCon.Close()
 
 	slog.Info("Start update...")
 
-	// update the local useflags
-	repository.UpdateUse("profiles/use.local.desc")
+	// wait for updates of other processes, e.g. triggered by webhooks
+	return database.WithLock(database.UpdateLockKey, update)
+}
+
+// update executes a single update run while holding the update lock
+func update() error {
+	// heads that can't be verified are not imported
+	if _, err := utils.SyncRepository(); err != nil {
+		return fmt.Errorf("failed syncing repository: %w", err)
+	}
+
+	run, err := startUpdateRun()
+	if err != nil {
+		return fmt.Errorf("failed starting update run: %w", err)
+	}
+
+	changed := utils.ChangedFiles(run.StartCommit, run.EndCommit)
+
+	for _, phase := range remainingPhases(run.Phase) {
+		run.Phase = phase.name
+		saveUpdateRun(run)
+
+		slog.Info("Running update phase", slog.Int64("run", run.Id), slog.String("phase", phase.name))
+		err := database.InTransaction(func(tx orm.DB) error {
+			return phase.run(tx, run, changed)
+		})
+		if err != nil {
+			run.Status = models.UpdateRunFailed
+			run.Error = err.Error()
+			run.FinishedAt = time.Now()
+			saveUpdateRun(run)
+			return fmt.Errorf("failed update phase %s of run %d, rolled back: %w", phase.name, run.Id, err)
+		}
+	}
+
+	run.Status = models.UpdateRunFinished
+	run.Error = ""
+	run.FinishedAt = time.Now()
+	saveUpdateRun(run)
+	return nil
+}
+
+// updatePhase is a single phase of an update run. All queries of
+// the phase are done using the transaction passed to the phase.
+type updatePhase struct {
+	name string
+	run  func(tx orm.DB, run *models.UpdateRun, changed []string) error
+}
+
+var updatePhases = []updatePhase{
+	{"metadata", func(tx orm.DB, _ *models.UpdateRun, changed []string) error {
+		// update the local useflags
+		if err := repository.UpdateUse(tx, "profiles/use.local.desc"); err != nil {
+			return err
+		}
+		return updateMetadata(tx, changed)
+	}},
+	{"package-data", func(tx orm.DB, _ *models.UpdateRun, changed []string) error {
+		return updatePackageData(tx, changed)
+	}},
+	{"history", func(tx orm.DB, run *models.UpdateRun, _ []string) error {
+		return updateHistory(tx, run.EndCommit)
+	}},
+	{"derived-data", func(tx orm.DB, _ *models.UpdateRun, _ []string) error {
+		if err := repository.CalculateMaskedVersions(tx); err != nil {
+			return err
+		}
+		return repository.CalculateDeprecatedToVersion(tx)
+	}},
+	{"version-history", func(tx orm.DB, run *models.UpdateRun, _ []string) error {
+		return repository.UpdateVersionHistory(tx, run.EndCommit)
+	}},
+	{"search-documents", func(tx orm.DB, _ *models.UpdateRun, changed []string) error {
+		return repository.UpdateSearchDocuments(tx, changed)
+	}},
+	{"maintainers", func(tx orm.DB, _ *models.UpdateRun, _ []string) error {
+		return maintainers.UpdateMaintainers(tx)
+	}},
+}
+
+// remainingPhases returns the phases starting with the given
+// phase, or all phases in case the phase is unknown
+func remainingPhases(phase string) []updatePhase {
+	for i, p := range updatePhases {
+		if p.name == phase {
+			return updatePhases[i:]
+		}
+	}
+	return updatePhases
+}
+
+// startUpdateRun returns the update run to execute. An unfinished run is
+// resumed at the phase it stopped in case the checked out commit didn't
+// change in the meantime. Otherwise it is superseded by a new run, which
+// starts at the same commit, so that all phases are redone up to the new
+// head. In case the last run has finished, the new run starts at the latest
+// commit in the database.
+func startUpdateRun() (*models.UpdateRun, error) {
+	head, err := utils.GetHead()
+	if err != nil {
+		return nil, err
+	}
 
-	latestCommit := utils.GetLatestCommit()
-	changed := utils.ChangedFiles(latestCommit, "HEAD")
+	var last models.UpdateRun
+	err = database.DBCon.Model(&last).Order("id DESC").Limit(1).Select()
+	if err != nil && err != pg.ErrNoRows {
+		return nil, err
+	}
 
-	updateMetadata(changed)
-	updatePackageData(changed)
-	updateHistory()
+	startCommit := utils.GetLatestCommit(database.DBCon)
+	if err == nil && last.Status != models.UpdateRunFinished {
+		if last.EndCommit == head {
+			slog.Info("Resuming unfinished update run", slog.Int64("run", last.Id), slog.String("phase", last.Phase))
+			last.Status = models.UpdateRunRunning
+			last.Error = ""
+			saveUpdateRun(&last)
+			return &last, nil
+		}
 
-	repository.CalculateMaskedVersions()
-	repository.CalculateDeprecatedToVersion()
+		slog.Info("Superseding unfinished update run", slog.Int64("run", last.Id), slog.String("phase", last.Phase))
+		last.Status = models.UpdateRunSuperseded
+		last.FinishedAt = time.Now()
+		saveUpdateRun(&last)
+		startCommit = last.StartCommit
+	}
+
+	run := &models.UpdateRun{
+		StartCommit: startCommit,
+		EndCommit:   head,
+		Status:      models.UpdateRunRunning,
+		StartedAt:   time.Now(),
+	}
+	_, err = database.DBCon.Model(run).Insert()
+	return run, err
+}
+
+// saveUpdateRun stores the current state of the update run. This
+// is done outside of the transactions of the phases, so that the
+// state is kept in case a phase is rolled back.
+func saveUpdateRun(run *models.UpdateRun) {
+	_, err := database.DBCon.Model(run).WherePK().Update()
+	if err != nil {
+		slog.Error("Failed updating update run", slog.Int64("run", run.Id), slog.Any("err", err))
+	}
 }
 
 // updateMetadata updates all USE flags, package masks and arches in the database
@@ -49,6 +190,7 @@ func Update() {
 //   - profiles/package.mask
 //   - profiles/package.deprecated
 //   - profiles/arch.list
+//   - profiles/arches.desc
 //   - profiles/updates/*
 //
 // It works incrementally so that files are only parsed and updated whenever the
@@ -56,15 +198,27 @@ func Update() {
 // retrieving the last commit in the database (if present) and parsing all
 // following commits. In case no last commit is present a full import
 // starting with the first commit in the tree is done.
-func updateMetadata(changed []string) {
+func updateMetadata(db orm.DB, changed []string) error {
 	slog.Info("Start updating changed metadata")
 	slog.Info("Iterating changed files", slog.Int("count", len(changed)))
-	repository.UpdatePkgMoves(changed)
+	if err := repository.UpdatePkgMoves(db, changed); err != nil {
+		return err
+	}
+	if err := repository.UpdateArches(db, changed); err != nil {
+		return err
+	}
 	for _, path := range changed {
-		repository.UpdateUse(path)
-		repository.UpdateMask(path)
-		repository.UpdatePackagesDeprecated(path)
+		if err := repository.UpdateUse(db, path); err != nil {
+			return err
+		}
+		if err := repository.UpdateMask(db, path); err != nil {
+			return err
+		}
+		if err := repository.UpdatePackagesDeprecated(db, path); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 // updatePackageData incrementally updates all package data in the database, that has
@@ -74,23 +228,31 @@ func updateMetadata(changed []string) {
 //   - versions
 //
 // changed data is determined by parsing all commits since the last update.
-func updatePackageData(changed []string) {
+func updatePackageData(db orm.DB, changed []string) error {
 	slog.Info("Start updating changed package data")
 	slog.Info("Iterating changed files", slog.Int("count", len(changed)))
 
-	repository.UpdateVersions(changed)
-	repository.UpdatePackages(changed)
-	repository.UpdateCategories(changed)
+	if err := repository.UpdateVersions(db, changed); err != nil {
+		return err
+	}
+	if err := repository.UpdatePackages(db, changed); err != nil {
+		return err
+	}
+	return repository.UpdateCategories(db, changed)
 }
 
-// updateHistory incrementally imports all new commits. New commits are
-// determined by retrieving the last commit in the database (if present)
-// and parsing all following commits. In case no last commit is present
-// a full import starting with the first commit in the tree is done.
-func updateHistory() {
+// updateHistory incrementally imports all new commits up to the given
+// endCommit. New commits are determined by retrieving the last commit in
+// the database (if present) and parsing all following commits. In case
+// no last commit is present a full import starting with the first commit
+// in the tree is done.
+func updateHistory(db orm.DB, endCommit string) error {
 	slog.Info("Start updating the history")
 
-	latestCommit := repository.UpdateCommits()
+	latestCommit, err := repository.UpdateCommits(db, endCommit)
+	if err != nil {
+		return err
+	}
 
 	if strings.TrimSpace(latestCommit) == "" {
 		currentApplicationData := getApplicationData()
@@ -104,10 +266,11 @@ func updateHistory() {
 		LastCommit: latestCommit,
 	}
 
-	_, err := database.DBCon.Model(application).OnConflict("(id) DO UPDATE").Insert()
+	_, err = db.Model(application).OnConflict("(id) DO UPDATE").Insert()
 	if err != nil {
 		slog.Error("Failed updating application data", slog.Any("err", err))
 	}
+	return err
 }
 
 // FullUpdate does - as the name applies - a full update. That is, it
@@ -120,32 +283,36 @@ func updateHistory() {
 // outdated data, which indicates bugs in the incremental update.
 // Once there is no outdated data found anymore this method may become
 // obsolete.
-func FullUpdate() {
+func FullUpdate() error {
 	database.Connect()
 	defer database.DBCon.Close()
 
 	slog.Info("Full update up...")
 
+	if _, err := utils.SyncRepository(); err != nil {
+		return fmt.Errorf("failed syncing repository: %w", err)
+	}
+
 	// Add new entries & update existing
 	slog.Info("Update all present files")
 
 	// update useflags
 	database.TruncateTable((*models.Useflag)(nil))
-	repository.UpdateUse("profiles/use.desc")
-	repository.UpdateUse("profiles/use.local.desc")
+	repository.UpdateUse(database.DBCon, "profiles/use.desc")
+	repository.UpdateUse(database.DBCon, "profiles/use.local.desc")
 	if entries, err := os.ReadDir(config.PortDir() + "/profiles/desc"); err != nil {
 		slog.Error("Error reading profiles/desc", slog.Any("err", err))
 	} else {
 		for _, entry := range entries {
-			repository.UpdateUse("profiles/desc/" + entry.Name())
+			repository.UpdateUse(database.DBCon, "profiles/desc/"+entry.Name())
 		}
 	}
 
 	allFiles := utils.AllFiles()
-	updateMetadata(allFiles)
-	repository.UpdateVersions(allFiles)
-	repository.UpdatePackages(allFiles)
-	repository.UpdateCategories(allFiles)
+	updateMetadata(database.DBCon, allFiles)
+	repository.UpdateVersions(database.DBCon, allFiles)
+	repository.UpdatePackages(database.DBCon, allFiles)
+	repository.UpdateCategories(database.DBCon, allFiles)
 
 	// Delete removed entries
 	slog.Info("Delete removed files from the database")
@@ -155,10 +322,16 @@ func FullUpdate() {
 
 	fixPrecedingCommitsOfPackages()
 
-	repository.CalculateMaskedVersions()
-	repository.CalculateDeprecatedToVersion()
+	repository.CalculateMaskedVersions(database.DBCon)
+	repository.CalculateDeprecatedToVersion(database.DBCon)
+	repository.UpdateVersionHistory(database.DBCon, utils.GetLatestCommit(database.DBCon))
+	repository.UpdateSearchDocuments(database.DBCon, allFiles)
+	if err := maintainers.UpdateMaintainers(database.DBCon); err != nil {
+		return fmt.Errorf("failed updating maintainers: %w", err)
+	}
 
 	slog.Info("Finished update up...")
+	return nil
 }
 
 // deleteRemovedVersions removes all versions from the database
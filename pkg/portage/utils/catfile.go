@@ -0,0 +1,88 @@
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Contains utility functions to read objects from the git repository
+
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os/exec"
+	"soko/pkg/config"
+	"strconv"
+	"strings"
+)
+
+// BlobReader reads the content of files at given revisions using a
+// long running 'git cat-file --batch' process, so that no process
+// has to be forked for each file. A BlobReader must not be used
+// concurrently.
+type BlobReader struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+}
+
+// NewBlobReader starts a new 'git cat-file --batch'
+// process in the repository at config.PortDir()
+func NewBlobReader() (*BlobReader, error) {
+	cmd := exec.Command("git", "cat-file", "--batch")
+	cmd.Dir = config.PortDir()
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &BlobReader{
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: bufio.NewReaderSize(stdout, 64*1024),
+	}, nil
+}
+
+// Read returns the content of the file at the given path in the given
+// revision. In case the file does not exist in this revision, found is
+// false.
+func (r *BlobReader) Read(revision, path string) (content []byte, found bool, err error) {
+	if _, err = fmt.Fprintf(r.stdin, "%s:%s\n", revision, path); err != nil {
+		return nil, false, err
+	}
+
+	header, err := r.stdout.ReadString('\n')
+	if err != nil {
+		return nil, false, err
+	}
+	header = strings.TrimSuffix(header, "\n")
+	if strings.HasSuffix(header, " missing") || strings.HasSuffix(header, " ambiguous") {
+		return nil, false, nil
+	}
+
+	fields := strings.Fields(header)
+	if len(fields) != 3 {
+		return nil, false, fmt.Errorf("unexpected cat-file header %q", header)
+	}
+	size, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return nil, false, fmt.Errorf("unexpected cat-file header %q: %w", header, err)
+	}
+
+	// the content is followed by a newline
+	content = make([]byte, size+1)
+	if _, err = io.ReadFull(r.stdout, content); err != nil {
+		return nil, false, err
+	}
+	return content[:size], true, nil
+}
+
+// Close stops the underlying git process
+func (r *BlobReader) Close() error {
+	r.stdin.Close()
+	return r.cmd.Wait()
+}
@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: GPL-2.0-only
+package utils
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestBlobReader(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("SOKO_PORT_DIR", dir)
+
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.org"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	write := func(path, content string) {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, path)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, path), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	git("init", "-q")
+	write("app-misc/foo/foo-1.0.ebuild", "EAPI=8\nKEYWORDS=\"~amd64\"\n")
+	git("add", "-A")
+	git("commit", "-q", "-m", "first")
+	write("app-misc/foo/foo-1.0.ebuild", "EAPI=8\nKEYWORDS=\"amd64\"\n")
+	git("commit", "-q", "-a", "-m", "second")
+
+	reader, err := NewBlobReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	testCases := []struct {
+		revision, path string
+		found          bool
+		expected       string
+	}{
+		{"HEAD", "app-misc/foo/foo-1.0.ebuild", true, "EAPI=8\nKEYWORDS=\"amd64\"\n"},
+		{"HEAD^", "app-misc/foo/foo-1.0.ebuild", true, "EAPI=8\nKEYWORDS=\"~amd64\"\n"},
+		{"HEAD", "app-misc/foo/foo-2.0.ebuild", false, ""},
+		{"HEAD", "app-misc/foo/foo-1.0.ebuild", true, "EAPI=8\nKEYWORDS=\"amd64\"\n"},
+	}
+	for _, tc := range testCases {
+		content, found, err := reader.Read(tc.revision, tc.path)
+		if err != nil {
+			t.Fatalf("Reading %s:%s failed: %v", tc.revision, tc.path, err)
+		}
+		if found != tc.found || string(content) != tc.expected {
+			t.Errorf("Expected %q (found %v) for %s:%s, got %q (found %v)", tc.expected, tc.found, tc.revision, tc.path, content, found)
+		}
+	}
+}
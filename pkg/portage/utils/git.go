@@ -8,18 +8,19 @@ import (
 	"log/slog"
 	"os/exec"
 	"soko/pkg/config"
-	"soko/pkg/database"
 	"soko/pkg/models"
 	"strings"
+
+	"github.com/go-pg/pg/v10/orm"
 )
 
-// ChangedFiles returns a list of files that are
-// currently present in the master branch
+// AllFiles returns a list of files that are
+// currently present in the configured branch
 func AllFiles() []string {
 	var allFiles []string
 	cmd := exec.Command("git",
 		"ls-tree",
-		"-r", "master",
+		"-r", config.GitBranch(),
 		"--name-only")
 	cmd.Dir = config.PortDir()
 	out, err := cmd.CombinedOutput()
@@ -34,12 +35,15 @@ func AllFiles() []string {
 
 // ChangedFiles returns a list of files that have been changed
 // between the startCommit and the endCommit. The status of the
-// change as well as the path to the file is returned for each file
+// change as well as the path to the file is returned for each file.
+// Renamed files are reported as a deletion of the old path followed
+// by an addition of the new path, copied files as an addition.
 func ChangedFiles(startCommit string, endCommit string) []string {
 	var changedFiles []string
 	cmd := exec.Command("git", "--no-pager",
 		"diff",
 		"--name-status",
+		"--find-renames",
 		startCommit+".."+endCommit)
 
 	cmd.Dir = config.PortDir()
@@ -49,16 +53,37 @@ func ChangedFiles(startCommit string, endCommit string) []string {
 		return changedFiles
 	}
 
-	changedFiles = strings.Split(string(out), "\n")
+	for _, line := range strings.Split(string(out), "\n") {
+		changedFiles = append(changedFiles, ExpandRename(line)...)
+	}
 	return changedFiles
 }
 
+// ExpandRename converts a rename or copy line of the git --name-status
+// output, that is 'R100\told\tnew' or 'C075\told\tnew', into the
+// corresponding deleted and added lines. All other lines are returned
+// unchanged.
+func ExpandRename(line string) []string {
+	parts := strings.Split(line, "\t")
+	if len(parts) != 3 {
+		return []string{line}
+	}
+	switch {
+	case strings.HasPrefix(parts[0], "R"):
+		return []string{"D\t" + parts[1], "A\t" + parts[2]}
+	case strings.HasPrefix(parts[0], "C"):
+		return []string{"A\t" + parts[2]}
+	}
+	return []string{line}
+}
+
 // GetCommits returns the log message of all commits after
 // the given startCommit and before the given endCommit. The
 // log message:
 //   - uses '%Y-%m-%dT%H:%M:%S%z' as date format
 //   - doesn't include merges
-//   - doesn't include renames
+//   - includes renames and copies as 'R<score>' and 'C<score>'
+//     followed by the old and the new path
 //   - includes the status of the changed files
 //
 // Furthermore the commits are in reverse order.
@@ -67,7 +92,8 @@ func GetCommits(startCommit string, endCommit string) []string {
 	cmd := exec.Command("git", "--no-pager",
 		"log",
 		"--name-status",
-		"--no-renames",
+		"--find-renames",
+		"--find-copies",
 		"--no-merges",
 		"--date=format:'%Y-%m-%dT%H:%M:%S%z'",
 		"--format=fuller",
@@ -85,27 +111,40 @@ func GetCommits(startCommit string, endCommit string) []string {
 	return commits
 }
 
+// GetHead returns the hash of the commit
+// that is currently checked out
+func GetHead() (string, error) {
+	lines, err := Exec(config.PortDir(), "git", "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return lines[0], nil
+}
+
 // GetLatestCommit retrieves the latest commit in
 // the database and returns the hash of the commit
-func GetLatestCommit() string {
-	latestCommit, _ := GetLatestCommitAndPreceding()
+func GetLatestCommit(db orm.DB) string {
+	latestCommit, _, _ := GetLatestCommitAndPreceding(db)
 	return latestCommit
 }
 
 // GetLatestCommitAndPreceding retrieves the latest
 // commit in the database. The hash of the latest commit
 // as well as the number of preceding commits is returned
-func GetLatestCommitAndPreceding() (latestCommit string, precedingCommitsOffset int) {
+func GetLatestCommitAndPreceding(db orm.DB) (latestCommit string, precedingCommitsOffset int, err error) {
 	var commits []*models.Commit
-	err := database.DBCon.Model(&commits).
+	err = db.Model(&commits).
 		Order("preceding_commits DESC").
 		Limit(1).
 		Select()
-	if err == nil && len(commits) == 1 {
-		return commits[0].Id, commits[0].PrecedingCommits
+	if err != nil {
+		return EmptyTree, 0, err
+	}
+	if len(commits) == 1 {
+		return commits[0].Id, commits[0].PrecedingCommits, nil
 	}
 
-	return EmptyTree, 0
+	return EmptyTree, 0, nil
 }
 
 // EmptyTree returns the hash of the empty tree
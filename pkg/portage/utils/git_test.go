@@ -0,0 +1,29 @@
+// SPDX-License-Identifier: GPL-2.0-only
+package utils
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestExpandRename(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected []string
+	}{
+		{"M\tdev-lang/perl/perl-5.40.0.ebuild", []string{"M\tdev-lang/perl/perl-5.40.0.ebuild"}},
+		{"D\tdev-lang/perl/perl-5.38.2.ebuild", []string{"D\tdev-lang/perl/perl-5.38.2.ebuild"}},
+		{"R100\tdev-python/foo/metadata.xml\tdev-py/foo/metadata.xml", []string{"D\tdev-python/foo/metadata.xml", "A\tdev-py/foo/metadata.xml"}},
+		{"R087\tapp-misc/bar/bar-1.0.ebuild\tapp-misc/bar/bar-1.0-r1.ebuild", []string{"D\tapp-misc/bar/bar-1.0.ebuild", "A\tapp-misc/bar/bar-1.0-r1.ebuild"}},
+		{"C075\tapp-misc/bar/bar-1.0.ebuild\tapp-misc/bar/bar-1.1.ebuild", []string{"A\tapp-misc/bar/bar-1.1.ebuild"}},
+		{"", []string{""}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			got := ExpandRename(tc.input)
+			if !slices.Equal(got, tc.expected) {
+				t.Errorf("Expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Contains utility functions to clone and fetch the repository
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"soko/pkg/config"
+	"soko/pkg/database"
+	"soko/pkg/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SyncRepository fetches the configured branch from the configured remote
+// into config.PortDir(), which is initialized first in case there is no
+// repository yet. The fetched head is only checked out in case its
+// signature has been made by a key of the configured keyring. Afterwards
+// the md5-cache is regenerated, so that it matches the checked out head.
+// The checked out head is returned and recorded as RepositoryHead.
+func SyncRepository() (string, error) {
+	if !config.GitSync() {
+		return GetHead()
+	}
+
+	state := &models.RepositoryHead{
+		Id:     "repository",
+		Remote: config.GitRemote(),
+		Branch: config.GitBranch(),
+	}
+	database.DBCon.Model(state).WherePK().Select()
+	state.Remote = config.GitRemote()
+	state.Branch = config.GitBranch()
+	state.SyncedAt = time.Now()
+
+	head, signer, err := fetchAndVerify()
+	if err != nil {
+		state.Error = err.Error()
+		saveRepositoryHead(state)
+		return "", err
+	}
+
+	if _, err := gitOutput(nil, "checkout", "--force", "-B", config.GitBranch(), head); err != nil {
+		state.Error = err.Error()
+		saveRepositoryHead(state)
+		return "", err
+	}
+
+	if err := regenMetadata(); err != nil {
+		state.Error = err.Error()
+		saveRepositoryHead(state)
+		return "", err
+	}
+
+	state.Head = head
+	state.Signer = signer
+	state.Verified = config.GitVerify()
+	state.Error = ""
+	saveRepositoryHead(state)
+	slog.Info("Synced repository", slog.String("branch", state.Branch), slog.String("head", head), slog.String("signer", signer))
+	return head, nil
+}
+
+// fetchAndVerify fetches the configured branch and returns the fetched
+// head as well as its signer, in case the signature has been verified
+func fetchAndVerify() (head string, signer string, err error) {
+	if !FileExists(filepath.Join(config.PortDir(), ".git")) {
+		slog.Info("Initializing repository", slog.String("dir", config.PortDir()))
+		if err := os.MkdirAll(config.PortDir(), 0755); err != nil {
+			return "", "", err
+		}
+		if _, err := gitOutput(nil, "init", "--quiet"); err != nil {
+			return "", "", err
+		}
+	}
+
+	if _, err := gitOutput(nil, "fetch", "--quiet", config.GitRemote(), config.GitBranch()); err != nil {
+		return "", "", err
+	}
+	out, err := gitOutput(nil, "rev-parse", "FETCH_HEAD^{commit}")
+	if err != nil {
+		return "", "", err
+	}
+	head = strings.TrimSpace(out)
+
+	if !config.GitVerify() {
+		slog.Warn("Signature verification is disabled, checking out unverified head", slog.String("head", head))
+		return head, "", nil
+	}
+
+	home, err := os.MkdirTemp("", "soko-gnupg")
+	if err != nil {
+		return "", "", err
+	}
+	defer os.RemoveAll(home)
+	env := []string{"GNUPGHOME=" + home}
+
+	cmd := exec.Command("gpg", "--batch", "--quiet", "--import", config.GitKeyring())
+	cmd.Env = append(os.Environ(), env...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return "", "", fmt.Errorf("importing keyring %s: %w: %s", config.GitKeyring(), err, strings.TrimSpace(string(out)))
+	}
+
+	out, err = gitOutput(env, "log", "-1", "--format=%G?%n%GK%n%GS", head)
+	if err != nil {
+		return "", "", err
+	}
+	signer, err = checkSignature(out)
+	if err != nil {
+		return "", "", fmt.Errorf("refusing head %s: %w", head, err)
+	}
+	return head, signer, nil
+}
+
+// checkSignature parses the signature status, key and signer printed
+// by git log and returns the signer in case the signature is good
+func checkSignature(out string) (string, error) {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	for len(lines) < 3 {
+		lines = append(lines, "")
+	}
+	status, key, signer := lines[0], lines[1], lines[2]
+	if signer == "" {
+		signer = key
+	} else if key != "" {
+		signer += " (" + key + ")"
+	}
+
+	switch status {
+	case "G", "U":
+		// a good signature by a key of the keyring. The keys are
+		// trusted by being part of the keyring, so that unknown
+		// validity is accepted as well.
+		return signer, nil
+	case "N", "":
+		return "", errors.New("commit is not signed")
+	case "E":
+		return "", errors.New("commit is signed by a key that is not in the keyring")
+	case "X", "Y":
+		return "", errors.New("commit is signed by an expired key: " + signer)
+	case "R":
+		return "", errors.New("commit is signed by a revoked key: " + signer)
+	default:
+		return "", errors.New("bad signature by " + signer)
+	}
+}
+
+// regenMetadata regenerates the md5-cache as well as the local
+// USE flag descriptions in config.PortDir() like bin/update.sh
+func regenMetadata() error {
+	cmd := exec.Command("pmaint", "regen", "--threads", strconv.Itoa(runtime.NumCPU()),
+		"--use-local-desc", "--pkg-desc-index", config.PortDir())
+	if _, err := cmd.Output(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return fmt.Errorf("pmaint regen: %w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return err
+	}
+	return nil
+}
+
+// gitOutput runs git with the given arguments and
+// additional environment variables in config.PortDir()
+func gitOutput(env []string, arg ...string) (string, error) {
+	cmd := exec.Command("git", arg...)
+	cmd.Dir = config.PortDir()
+	cmd.Env = append(os.Environ(), env...)
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", fmt.Errorf("git %s: %w: %s", arg[0], err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", err
+	}
+	return string(out), nil
+}
+
+func saveRepositoryHead(state *models.RepositoryHead) {
+	_, err := database.DBCon.Model(state).OnConflict("(id) DO UPDATE").Insert()
+	if err != nil {
+		slog.Error("Failed updating repository head", slog.Any("err", err))
+	}
+}
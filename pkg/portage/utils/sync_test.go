@@ -0,0 +1,33 @@
+// SPDX-License-Identifier: GPL-2.0-only
+package utils
+
+import (
+	"testing"
+)
+
+func TestCheckSignature(t *testing.T) {
+	testCases := []struct {
+		input    string
+		signer   string
+		accepted bool
+	}{
+		{"G\nABCDEF\nLarry <larry@gentoo.org>\n", "Larry <larry@gentoo.org> (ABCDEF)", true},
+		{"U\nABCDEF\nLarry <larry@gentoo.org>\n", "Larry <larry@gentoo.org> (ABCDEF)", true},
+		{"N\n\n\n", "", false},
+		{"E\nABCDEF\n\n", "", false},
+		{"B\nABCDEF\nLarry <larry@gentoo.org>\n", "", false},
+		{"R\nABCDEF\nLarry <larry@gentoo.org>\n", "", false},
+		{"", "", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			signer, err := checkSignature(tc.input)
+			if (err == nil) != tc.accepted {
+				t.Fatalf("Expected accepted %t, got error %v", tc.accepted, err)
+			}
+			if signer != tc.signer {
+				t.Errorf("Expected %q, got %q", tc.signer, signer)
+			}
+		})
+	}
+}
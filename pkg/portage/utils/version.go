@@ -4,33 +4,58 @@ package utils
 import (
 	"log/slog"
 	"regexp"
-	"soko/pkg/database"
 	"soko/pkg/models"
 	"strings"
+
+	"github.com/go-pg/pg/v10/orm"
 )
 
 var (
 	revision = regexp.MustCompile(`-r[0-9]*$`)
+
+	// pmsRevision and pmsVersion match the revisions and
+	// versions of the package manager specification
+	pmsRevision = regexp.MustCompile(`^r[0-9]+$`)
+	pmsVersion  = regexp.MustCompile(`^[0-9]+(\.[0-9]+)*[a-z]?(_(alpha|beta|pre|rc|p)[0-9]*)*$`)
 )
 
-func CalculateAffectedVersions(versionSpecifier, packageAtom string) []*models.Version {
+// SplitPF splits the given PF, that is the package name followed by the
+// version and the optional revision, into the package name and the version
+// including the revision. The version is determined from the right, as
+// package names may contain a hyphen followed by digits, e.g. in case of
+// font-adobe-100dpi-1.0.4. false is returned if PF doesn't contain a version.
+func SplitPF(pf string) (string, string, bool) {
+	rest := pf
+	if i := strings.LastIndexByte(rest, '-'); i > 0 && pmsRevision.MatchString(rest[i+1:]) {
+		rest = rest[:i]
+	}
+	i := strings.LastIndexByte(rest, '-')
+	if i <= 0 || !pmsVersion.MatchString(rest[i+1:]) {
+		return "", "", false
+	}
+	return pf[:i], pf[i+1:], true
+}
+
+// CalculateAffectedVersions returns the versions matched by the given
+// version specifier, which are queried using the given handle
+func CalculateAffectedVersions(db orm.DB, versionSpecifier, packageAtom string) ([]*models.Version, error) {
 	switch {
 	case strings.HasPrefix(versionSpecifier, "="):
-		return exactVersion(versionSpecifier, packageAtom)
+		return exactVersion(db, versionSpecifier, packageAtom)
 	case strings.HasPrefix(versionSpecifier, "<="):
-		return comparedVersions("<=", versionSpecifier, packageAtom)
+		return comparedVersions(db, "<=", versionSpecifier, packageAtom)
 	case strings.HasPrefix(versionSpecifier, "<"):
-		return comparedVersions("<", versionSpecifier, packageAtom)
+		return comparedVersions(db, "<", versionSpecifier, packageAtom)
 	case strings.HasPrefix(versionSpecifier, ">="):
-		return comparedVersions(">=", versionSpecifier, packageAtom)
+		return comparedVersions(db, ">=", versionSpecifier, packageAtom)
 	case strings.HasPrefix(versionSpecifier, ">"):
-		return comparedVersions(">", versionSpecifier, packageAtom)
+		return comparedVersions(db, ">", versionSpecifier, packageAtom)
 	case strings.HasPrefix(versionSpecifier, "~"):
-		return allRevisions(versionSpecifier, packageAtom)
+		return allRevisions(db, versionSpecifier, packageAtom)
 	case strings.Contains(versionSpecifier, ":"):
-		return versionsWithSlot(versionSpecifier, packageAtom)
+		return versionsWithSlot(db, versionSpecifier, packageAtom)
 	default:
-		return allVersions(versionSpecifier, packageAtom)
+		return allVersions(db, versionSpecifier, packageAtom)
 	}
 }
 
@@ -45,13 +70,13 @@ func slotAndSubslot(versionSpecifier string) (string, []string) {
 }
 
 // comparedVersions computes and returns all versions that are >=, >, <= or < than then given version
-func comparedVersions(operator string, versionSpecifier string, packageAtom string) []*models.Version {
+func comparedVersions(db orm.DB, operator string, versionSpecifier string, packageAtom string) ([]*models.Version, error) {
 	var results, versions []*models.Version
 	versionSpecifier = strings.ReplaceAll(versionSpecifier, operator, "")
 	versionSpecifier = strings.ReplaceAll(versionSpecifier, packageAtom+"-", "")
 	versionSpecifier, slots := slotAndSubslot(versionSpecifier)
 
-	q := database.DBCon.Model(&versions).
+	q := db.Model(&versions).
 		Where("atom = ?", packageAtom)
 	if len(slots) >= 1 {
 		q = q.Where("slot = ?", slots[0])
@@ -62,7 +87,7 @@ func comparedVersions(operator string, versionSpecifier string, packageAtom stri
 	err := q.Select()
 	if err != nil {
 		slog.Error("Failed fetching versions", slog.Any("err", err))
-		return nil
+		return nil, err
 	}
 
 	for _, v := range versions {
@@ -86,17 +111,17 @@ func comparedVersions(operator string, versionSpecifier string, packageAtom stri
 			}
 		}
 	}
-	return results
+	return results, nil
 }
 
 // allRevisions returns all revisions of the given version
-func allRevisions(versionSpecifier string, packageAtom string) []*models.Version {
+func allRevisions(db orm.DB, versionSpecifier string, packageAtom string) ([]*models.Version, error) {
 	var versions []*models.Version
 	versionSpecifier, slots := slotAndSubslot(versionSpecifier)
 	versionWithoutRevision := revision.Split(versionSpecifier, 1)[0]
 	versionWithoutRevision = strings.ReplaceAll(versionWithoutRevision, "~", "")
 
-	q := database.DBCon.Model(&versions).
+	q := db.Model(&versions).
 		Where("id LIKE ?", versionWithoutRevision+"%")
 	if len(slots) >= 1 {
 		q = q.Where("slot = ?", slots[0])
@@ -109,15 +134,15 @@ func allRevisions(versionSpecifier string, packageAtom string) []*models.Version
 		slog.Error("Failed fetching versions", slog.Any("err", err))
 	}
 
-	return versions
+	return versions, err
 }
 
 // exactVersion returns the exact version specified in the versionSpecifier
-func exactVersion(versionSpecifier string, packageAtom string) []*models.Version {
+func exactVersion(db orm.DB, versionSpecifier string, packageAtom string) ([]*models.Version, error) {
 	var versions []*models.Version
 	versionSpecifier, slots := slotAndSubslot(versionSpecifier)
 
-	q := database.DBCon.Model(&versions).
+	q := db.Model(&versions).
 		Where("id = ?", strings.Replace(versionSpecifier, "=", "", 1))
 	if len(slots) >= 1 {
 		q = q.Where("slot = ?", slots[0])
@@ -130,15 +155,15 @@ func exactVersion(versionSpecifier string, packageAtom string) []*models.Version
 		slog.Error("Failed fetching versions", slog.Any("err", err))
 	}
 
-	return versions
+	return versions, err
 }
 
 // versionsWithSlot returns all versions with the given slot
-func versionsWithSlot(versionSpecifier string, packageAtom string) []*models.Version {
+func versionsWithSlot(db orm.DB, versionSpecifier string, packageAtom string) ([]*models.Version, error) {
 	var versions []*models.Version
 	_, slots := slotAndSubslot(versionSpecifier)
 
-	q := database.DBCon.Model(&versions).
+	q := db.Model(&versions).
 		Where("atom = ?", packageAtom).
 		Where("slot = ?", slots[0])
 	if len(slots) == 2 {
@@ -149,14 +174,14 @@ func versionsWithSlot(versionSpecifier string, packageAtom string) []*models.Ver
 		slog.Error("Failed fetching versions", slog.Any("err", err))
 	}
 
-	return versions
+	return versions, err
 }
 
 // allVersions returns all versions of the given package
-func allVersions(versionSpecifier string, packageAtom string) []*models.Version {
+func allVersions(db orm.DB, versionSpecifier string, packageAtom string) ([]*models.Version, error) {
 	var versions []*models.Version
-	_ = database.DBCon.Model(&versions).
+	err := db.Model(&versions).
 		Where("atom = ?", packageAtom).
 		Select()
-	return versions
+	return versions, err
 }
@@ -0,0 +1,33 @@
+// SPDX-License-Identifier: GPL-2.0-only
+package utils
+
+import (
+	"testing"
+)
+
+func TestSplitPF(t *testing.T) {
+	testCases := []struct {
+		pf      string
+		name    string
+		version string
+		found   bool
+	}{
+		{"foo-1.2", "foo", "1.2", true},
+		{"foo-bar-1.2-r1", "foo-bar", "1.2-r1", true},
+		{"font-adobe-100dpi-1.0.4", "font-adobe-100dpi", "1.0.4", true},
+		{"font-adobe-100dpi-1.0.4-r1", "font-adobe-100dpi", "1.0.4-r1", true},
+		{"gtk+-3.24.41_p20240101", "gtk+", "3.24.41_p20240101", true},
+		{"foo-2-1.0b_rc1_p2", "foo-2", "1.0b_rc1_p2", true},
+		{"foo", "", "", false},
+		{"foo-r1", "", "", false},
+		{"-1.0", "", "", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.pf, func(t *testing.T) {
+			name, version, found := SplitPF(tc.pf)
+			if name != tc.name || version != tc.version || found != tc.found {
+				t.Errorf("Expected %q, %q, %t, got %q, %q, %t", tc.name, tc.version, tc.found, name, version, found)
+			}
+		})
+	}
+}
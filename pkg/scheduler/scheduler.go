@@ -0,0 +1,379 @@
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Runs the update jobs periodically as daemon
+
+package scheduler
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+
+	"soko/pkg/config"
+	"soko/pkg/database"
+	"soko/pkg/models"
+)
+
+// lockKey is the key of the PostgreSQL advisory lock,
+// which ensures that only one scheduler is running
+const lockKey = 0x736f6b6f
+
+// tick is the interval in which due jobs are checked
+const tick = time.Minute
+
+// pushTick is the interval in which pending updates requested
+// by push webhooks are checked, so that pushes show up within
+// seconds instead of waiting for the next tick
+const pushTick = 2 * time.Second
+
+// Job is a job run periodically by the scheduler. A job is due once
+// Interval has passed since its last start. Jobs listed in TriggeredBy
+// make the job due as well once they have finished successfully since
+// the last start of the job, so that the job picks up their data right
+// away. Before a job is run, it waits for the jobs listed in After and
+// TriggeredBy that are due or running, so that it never uses the data
+// of a dependency that is about to change. A failed dependency doesn't
+// prevent the job from running with the data of the last successful run.
+// Jobs with OnPush set are due as well once updates have been
+// requested by push webhooks. They are run independently of the
+// other jobs, so that a push doesn't wait for long running jobs,
+// which is why they must not share any state with the other jobs,
+// e.g. by running in a process of their own.
+type Job struct {
+	Name        string
+	Interval    time.Duration
+	After       []string
+	TriggeredBy []string
+	OnPush      bool
+	Run         func() error
+}
+
+// dependencies returns the names of all jobs the job depends on
+func (job *Job) dependencies() []string {
+	return slices.Concat(job.After, job.TriggeredBy)
+}
+
+type scheduler struct {
+	db     *pg.DB
+	jobs   []*Job
+	byName map[string]*Job
+	// mu guards the states, which are shared by the job
+	// lanes of the jobs with and without OnPush set
+	mu     sync.Mutex
+	states map[string]*models.ScheduledJob
+}
+
+// Run runs the given jobs forever. Before running any job, the
+// scheduler waits until it holds the single-instance lock in the
+// database, so that two schedulers never run jobs at the same time.
+func Run(jobs []*Job) {
+	// make sure the schema is present, the jobs are
+	// connecting to the database on their own
+	database.Connect()
+	database.DBCon.Close()
+
+	sorted, err := sortJobs(jobs)
+	if err != nil {
+		slog.Error("Invalid job dependencies", slog.Any("err", err))
+		return
+	}
+
+	s := &scheduler{
+		db:     database.Open(),
+		jobs:   sorted,
+		byName: make(map[string]*Job, len(jobs)),
+		states: make(map[string]*models.ScheduledJob, len(jobs)),
+	}
+	for _, job := range jobs {
+		s.byName[job.Name] = job
+	}
+	defer s.db.Close()
+
+	// the advisory lock belongs to the session, so that
+	// a single connection has to be kept open
+	conn := s.db.Conn()
+	defer conn.Close()
+	s.acquireLock(conn)
+
+	s.loadStates()
+	go s.watchPushes()
+	for {
+		s.runDueJobs(false)
+
+		if _, err := conn.Exec("SELECT 1"); err != nil {
+			slog.Error("Lost connection holding the scheduler lock", slog.Any("err", err))
+			conn.Close()
+			conn = s.db.Conn()
+			s.acquireLock(conn)
+		}
+		time.Sleep(tick)
+	}
+}
+
+// acquireLock blocks until the scheduler lock is held
+func (s *scheduler) acquireLock(conn *pg.Conn) {
+	for {
+		var locked bool
+		_, err := conn.QueryOne(pg.Scan(&locked), "SELECT pg_try_advisory_lock(?)", lockKey)
+		if err != nil {
+			slog.Error("Failed acquiring scheduler lock", slog.Any("err", err))
+		} else if locked {
+			slog.Info("Acquired scheduler lock")
+			return
+		} else {
+			slog.Info("Another scheduler is running, waiting for the lock")
+		}
+		time.Sleep(tick)
+	}
+}
+
+// loadStates loads the state of the jobs from the database, so
+// that the jobs keep their schedule when the daemon is restarted
+func (s *scheduler) loadStates() {
+	var rows []*models.ScheduledJob
+	err := s.db.Model(&rows).Select()
+	if err != nil {
+		slog.Error("Failed fetching scheduled jobs", slog.Any("err", err))
+	}
+	for _, row := range rows {
+		s.states[row.Name] = row
+	}
+
+	for _, job := range s.jobs {
+		state, found := s.states[job.Name]
+		if !found {
+			state = &models.ScheduledJob{Name: job.Name}
+			s.states[job.Name] = state
+		}
+		if state.Status == models.ScheduledJobRunning {
+			// the previous daemon died while running the job
+			state.Status = models.ScheduledJobFailed
+			state.Error = "interrupted"
+		} else if state.Status == "" {
+			state.Status = models.ScheduledJobIdle
+		}
+		state.Interval = job.Interval.String()
+		state.After = job.After
+		state.TriggeredBy = job.TriggeredBy
+		state.NextRun = nextRun(job, state)
+		s.saveState(state)
+	}
+}
+
+// watchPushes runs the jobs with OnPush set forever, both
+// periodically and once updates have been requested by pushes
+func (s *scheduler) watchPushes() {
+	for {
+		s.runDueJobs(true)
+		time.Sleep(pushTick)
+	}
+}
+
+// runDueJobs runs all jobs that are due in dependency order, either the
+// jobs with OnPush set or the other ones depending on onPush. Pending
+// updates requested by pushes are only handled by the former.
+func (s *scheduler) runDueJobs(onPush bool) {
+	var pushed bool
+	var received time.Time
+	if onPush {
+		pushed, received = s.pendingUpdates()
+	}
+	for _, job := range s.jobs {
+		if job.OnPush != onPush {
+			continue
+		}
+		if pushed {
+			s.mu.Lock()
+			s.states[job.Name].NextRun = time.Now()
+			s.mu.Unlock()
+		}
+		if s.due(job) {
+			s.awaitDependencies(job)
+			s.runJob(job)
+		}
+	}
+
+	if pushed {
+		// pushes received in the meantime are handled by the next run
+		_, err := s.db.Model((*models.PendingUpdate)(nil)).Where("received_at <= ?", received).Delete()
+		if err != nil {
+			slog.Error("Failed deleting pending updates", slog.Any("err", err))
+		}
+	}
+}
+
+// pendingUpdates reports whether updates requested by push webhooks are
+// due, as well as the time the last of them has been received. Bursts of
+// pushes are merged, see pushesDue.
+func (s *scheduler) pendingUpdates() (bool, time.Time) {
+	var first, last time.Time
+	_, err := s.db.QueryOne(pg.Scan(&first, &last), "SELECT MIN(received_at), MAX(received_at) FROM pending_updates")
+	if err != nil {
+		slog.Error("Failed fetching pending updates", slog.Any("err", err))
+		return false, last
+	}
+	if last.IsZero() || !pushesDue(first, last, time.Now(), config.WebhookDebounce()) {
+		return false, last
+	}
+	slog.Info("Running update requested by webhook", slog.Time("received", last))
+	return true, last
+}
+
+// pushesDue reports whether the pushes received between first and last
+// are due, that is no further push has been received within the debounce
+// delay. Continuous pushes must not delay the update forever though.
+func pushesDue(first, last, now time.Time, debounce time.Duration) bool {
+	return now.Sub(last) >= debounce || now.Sub(first) >= 10*debounce
+}
+
+// due reports whether the job is due, either because its interval has
+// passed or because a job triggering it has finished since its last start
+func (s *scheduler) due(job *Job) bool {
+	if job.Interval <= 0 {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return dueAt(job, s.states, time.Now())
+}
+
+// dueAt reports whether the job is due at the given time
+// given the states of all jobs
+func dueAt(job *Job, states map[string]*models.ScheduledJob, now time.Time) bool {
+	state := states[job.Name]
+	if !now.Before(state.NextRun) {
+		return true
+	}
+	for _, name := range job.TriggeredBy {
+		dependency := states[name]
+		if dependency.Status == models.ScheduledJobFinished && dependency.LastFinish.After(state.LastStart) {
+			return true
+		}
+	}
+	return false
+}
+
+// awaitDependencies runs the dependencies of the job that are due in the
+// same lane first and waits for the dependencies running in the other lane
+func (s *scheduler) awaitDependencies(job *Job) {
+	for _, name := range job.dependencies() {
+		dependency := s.byName[name]
+		if dependency.OnPush == job.OnPush {
+			if s.due(dependency) {
+				s.awaitDependencies(dependency)
+				s.runJob(dependency)
+			}
+			continue
+		}
+		for s.running(dependency) || s.due(dependency) {
+			time.Sleep(pushTick)
+		}
+	}
+}
+
+// running reports whether the job is running
+func (s *scheduler) running(job *Job) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.states[job.Name].Status == models.ScheduledJobRunning
+}
+
+// runJob runs the job and records its state. A job fails in case
+// it returns an error or panics.
+func (s *scheduler) runJob(job *Job) {
+	slog.Info("Running scheduled job", slog.String("job", job.Name))
+	s.mu.Lock()
+	state := s.states[job.Name]
+	state.Status = models.ScheduledJobRunning
+	state.LastStart = time.Now()
+	state.Error = ""
+	s.saveState(state)
+	s.mu.Unlock()
+
+	var err error
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if err != nil {
+			slog.Error("Scheduled job failed", slog.String("job", job.Name), slog.Any("err", err))
+			state.Status = models.ScheduledJobFailed
+			state.Error = err.Error()
+		} else {
+			state.Status = models.ScheduledJobFinished
+		}
+		state.LastFinish = time.Now()
+		state.NextRun = nextRun(job, state)
+		s.saveState(state)
+		slog.Info("Finished scheduled job", slog.String("job", job.Name), slog.Duration("duration", state.LastFinish.Sub(state.LastStart)))
+	}()
+
+	err = job.Run()
+}
+
+func (s *scheduler) saveState(state *models.ScheduledJob) {
+	_, err := s.db.Model(state).OnConflict("(name) DO UPDATE").Insert()
+	if err != nil {
+		slog.Error("Failed updating scheduled job", slog.String("job", state.Name), slog.Any("err", err))
+	}
+}
+
+// nextRun returns the time the job is due next
+func nextRun(job *Job, state *models.ScheduledJob) time.Time {
+	if state.LastStart.IsZero() {
+		return time.Now()
+	}
+	return state.LastStart.Add(job.Interval)
+}
+
+// sortJobs sorts the jobs topologically, so that each job is
+// placed after all jobs it depends on
+func sortJobs(jobs []*Job) ([]*Job, error) {
+	byName := make(map[string]*Job, len(jobs))
+	for _, job := range jobs {
+		byName[job.Name] = job
+	}
+
+	sorted := make([]*Job, 0, len(jobs))
+	visited := make(map[string]bool, len(jobs))
+	var visit func(job *Job, path []string) error
+	visit = func(job *Job, path []string) error {
+		if done, seen := visited[job.Name]; seen {
+			if !done {
+				return fmt.Errorf("cyclic dependency %v", append(path, job.Name))
+			}
+			return nil
+		}
+		visited[job.Name] = false
+		for _, name := range job.dependencies() {
+			dependency, found := byName[name]
+			if !found {
+				return fmt.Errorf("job %s depends on unknown job %s", job.Name, name)
+			}
+			if job.OnPush && !dependency.OnPush {
+				// the push lane must never wait for the other lane
+				return fmt.Errorf("job %s run on push depends on job %s not run on push", job.Name, name)
+			}
+			if err := visit(dependency, append(path, job.Name)); err != nil {
+				return err
+			}
+		}
+		visited[job.Name] = true
+		sorted = append(sorted, job)
+		return nil
+	}
+
+	for _, job := range jobs {
+		if err := visit(job, nil); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: GPL-2.0-only
+package scheduler
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"soko/pkg/models"
+)
+
+func TestSortJobs(t *testing.T) {
+	jobs := []*Job{
+		{Name: "update-maintainers", After: []string{"update-bugs", "update-pullrequests"}},
+		{Name: "update-bugs", After: []string{"update"}},
+		{Name: "update-pullrequests"},
+		{Name: "update"},
+	}
+	sorted, err := sortJobs(jobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := make([]string, len(sorted))
+	for i, job := range sorted {
+		names[i] = job.Name
+	}
+	expected := []string{"update", "update-bugs", "update-pullrequests", "update-maintainers"}
+	if !slices.Equal(names, expected) {
+		t.Errorf("Expected %q, got %q", expected, names)
+	}
+}
+
+func TestSortJobsInvalid(t *testing.T) {
+	testCases := map[string][]*Job{
+		"cycle": {
+			{Name: "a", After: []string{"b"}},
+			{Name: "b", After: []string{"a"}},
+		},
+		"unknown": {
+			{Name: "a", After: []string{"c"}},
+		},
+		"push depending on other lane": {
+			{Name: "update", OnPush: true, After: []string{"b"}},
+			{Name: "b"},
+		},
+	}
+	for name, jobs := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := sortJobs(jobs); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestPushesDue(t *testing.T) {
+	now := time.Now()
+	testCases := []struct {
+		name        string
+		first, last time.Time
+		expected    bool
+	}{
+		{"single push", now.Add(-time.Minute), now.Add(-time.Minute), true},
+		{"recent push", now.Add(-time.Minute), now.Add(-5 * time.Second), false},
+		{"continuous pushes", now.Add(-2 * time.Minute), now.Add(-time.Second), true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := pushesDue(tc.first, tc.last, now, 10*time.Second); got != tc.expected {
+				t.Errorf("Expected %t, got %t", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestDueAt(t *testing.T) {
+	now := time.Now()
+	job := &Job{Name: "update-maintainers", TriggeredBy: []string{"update-bugs"}}
+	testCases := []struct {
+		name     string
+		nextRun  time.Time
+		status   models.ScheduledJobStatus
+		finished time.Time
+		expected bool
+	}{
+		{"interval passed", now.Add(-time.Minute), models.ScheduledJobIdle, time.Time{}, true},
+		{"not due", now.Add(time.Minute), models.ScheduledJobFinished, now.Add(-2 * time.Hour), false},
+		{"triggered", now.Add(time.Minute), models.ScheduledJobFinished, now.Add(-time.Minute), true},
+		{"trigger failed", now.Add(time.Minute), models.ScheduledJobFailed, now.Add(-time.Minute), false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			states := map[string]*models.ScheduledJob{
+				"update-maintainers": {Name: "update-maintainers", LastStart: now.Add(-time.Hour), NextRun: tc.nextRun},
+				"update-bugs":        {Name: "update-bugs", Status: tc.status, LastFinish: tc.finished},
+			}
+			if got := dueAt(job, states, now); got != tc.expected {
+				t.Errorf("Expected %t, got %t", tc.expected, got)
+			}
+		})
+	}
+}
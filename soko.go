@@ -3,10 +3,12 @@ package main
 
 import (
 	"embed"
+	"errors"
 	"flag"
 	"log"
 	"log/slog"
 	"os"
+	"os/exec"
 	"time"
 
 	"github.com/lmittmann/tint"
@@ -17,13 +19,18 @@ import (
 	"soko/pkg/database"
 	"soko/pkg/portage"
 	"soko/pkg/portage/anitya"
+	"soko/pkg/portage/binhost"
 	"soko/pkg/portage/bugs"
 	"soko/pkg/portage/dependencies"
+	"soko/pkg/portage/developers"
+	"soko/pkg/portage/dumps"
+	"soko/pkg/portage/files"
 	"soko/pkg/portage/maintainers"
 	"soko/pkg/portage/pkgcheck"
 	"soko/pkg/portage/projects"
 	"soko/pkg/portage/pullrequests"
 	"soko/pkg/portage/repology"
+	"soko/pkg/scheduler"
 )
 
 //go:embed assets
@@ -42,47 +49,85 @@ func main() {
 	updateBugs := flag.Bool("update-bugs", false, "Update the bugs belonging to the packages")
 	updateDependencies := flag.Bool("update-dependencies", false, "Update the dependencies and reverse dependencies of the packages")
 	updateProjects := flag.Bool("update-projects", false, "Update the project information")
+	updateFiles := flag.Bool("update-files", false, "Update the files installed by the packages from SOKO_FILES_DIR")
+	updateBinhosts := flag.Bool("update-binhosts", false, "Update the binary packages from the binhosts in SOKO_BINHOSTS")
+	updateDevelopers := flag.Bool("update-developers", false, "Update the developers from the directory export in SOKO_DEVELOPERS_FILE")
+	export := flag.Bool("export", false, "Export the database as JSON Lines and SQLite dumps to SOKO_DUMPS_DIR")
 	updateMaintainers := flag.Bool("update-maintainers", false, "Update the maintainer information")
+	daemon := flag.Bool("daemon", false, "Run all update jobs periodically, see SOKO_SCHEDULE_* for their intervals")
 
 	help := flag.Bool("help", false, "Print the usage of this application")
 
 	flag.Parse()
 
+	// failed records whether any of the one-off jobs failed, so that
+	// soko exits with a non-zero status, e.g. when run by the daemon
+	failed := false
+	check := func(job string, err error) {
+		if err != nil {
+			slog.Error("Failed "+job, slog.Any("err", err))
+			failed = true
+		}
+	}
+
 	if *update {
 		slog.Info("Updating package data")
-		portage.Update()
+		check("updating package data", portage.Update())
 	}
 	if *fullupdate {
 		slog.Info("Performing full update of the package data")
-		portage.FullUpdate()
+		check("performing full update of the package data", portage.FullUpdate())
 	}
 	if *updateOutdatedPackages {
-		updateOutdated()
+		check("updating outdated packages", updateOutdated())
 	}
 	if *updatePkgcheckResults {
 		slog.Info("Updating the qa-reports that is the pkgcheck data")
-		pkgcheck.UpdatePkgCheckResults()
+		check("updating the pkgcheck data", pkgcheck.UpdatePkgCheckResults())
 	}
 	if *updatePullrequests {
 		slog.Info("Updating the pull requests data")
-		pullrequests.FullUpdatePullRequests()
+		check("updating the pull requests data", pullrequests.FullUpdatePullRequests())
 	}
 	if *updateBugs {
 		slog.Info("Updating the bugs data")
-		bugs.UpdateBugs()
+		check("updating the bugs data", bugs.UpdateBugs())
 	}
 	if *updateDependencies {
 		slog.Info("Updating the dependencies data")
-		dependencies.FullPackageDependenciesUpdate()
+		check("updating the dependencies data", dependencies.FullPackageDependenciesUpdate())
 	}
 	if *updateProjects {
-		projects.UpdateProjects()
+		check("updating the projects data", projects.UpdateProjects())
+	}
+	if *updateFiles {
+		slog.Info("Updating the files data")
+		check("updating the files data", files.UpdateFiles())
+	}
+	if *updateBinhosts {
+		slog.Info("Updating the binary packages data")
+		check("updating the binary packages data", binhost.UpdateBinaryPackages())
+	}
+	if *updateDevelopers {
+		slog.Info("Updating the developers data")
+		check("updating the developers data", developers.UpdateDevelopers())
+	}
+	if *export {
+		slog.Info("Exporting the database dumps")
+		check("exporting the database dumps", dumps.Export())
 	}
 	// updateMaintainers should always be executed last, as it is using
 	// the updated bugs, pullrequests and and outdated packages
 	if *updateMaintainers {
 		slog.Info("Updating the maintainers data")
-		maintainers.FullImport()
+		check("updating the maintainers data", maintainers.FullImport())
+	}
+
+	// the daemon has to run in its own process, as the jobs
+	// are replacing the database connection used by serve
+	if *daemon {
+		slog.Info("Starting the scheduler daemon")
+		scheduler.Run(scheduledJobs())
 	}
 
 	if *serve {
@@ -92,18 +137,131 @@ func main() {
 	if *help {
 		flag.PrintDefaults()
 	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// scheduledJobs returns the jobs run by the daemon. Jobs are run in the
+// order of their dependencies, like the ordering of the command line
+// flags, and wait for their dependencies that are due or running. Jobs
+// deriving data of other jobs are triggered once these have finished.
+// The update is run in a process of its own, so that it can run while
+// other jobs are replacing the database connection.
+func scheduledJobs() []*scheduler.Job {
+	return []*scheduler.Job{
+		{
+			Name:     "update",
+			Interval: config.ScheduleInterval("update", 5*time.Minute),
+			OnPush:   true,
+			Run:      runProcess("--update"),
+		},
+		{
+			Name:     "update-outdated-packages",
+			Interval: config.ScheduleInterval("update-outdated-packages", 6*time.Hour),
+			After:    []string{"update"},
+			Run:      updateOutdatedPackages,
+		},
+		{
+			Name:        "update-outdated-categories",
+			Interval:    config.ScheduleInterval("update-outdated-categories", 6*time.Hour),
+			TriggeredBy: []string{"update-outdated-packages"},
+			Run:         updateOutdatedCategories,
+		},
+		{
+			Name:     "update-pkgcheck-results",
+			Interval: config.ScheduleInterval("update-pkgcheck-results", time.Hour),
+			After:    []string{"update"},
+			Run:      pkgcheck.UpdatePkgCheckResults,
+		},
+		{
+			Name:     "update-pullrequests",
+			Interval: config.ScheduleInterval("update-pullrequests", time.Hour),
+			After:    []string{"update"},
+			Run:      pullrequests.FullUpdatePullRequests,
+		},
+		{
+			Name:     "update-bugs",
+			Interval: config.ScheduleInterval("update-bugs", time.Hour),
+			After:    []string{"update"},
+			Run:      bugs.UpdateBugs,
+		},
+		{
+			Name:     "update-dependencies",
+			Interval: config.ScheduleInterval("update-dependencies", time.Hour),
+			After:    []string{"update"},
+			Run:      dependencies.FullPackageDependenciesUpdate,
+		},
+		{
+			Name:     "update-projects",
+			Interval: config.ScheduleInterval("update-projects", 24*time.Hour),
+			Run:      projects.UpdateProjects,
+		},
+		{
+			Name:     "update-files",
+			Interval: config.ScheduleInterval("update-files", 24*time.Hour),
+			Run:      files.UpdateFiles,
+		},
+		{
+			Name:     "update-binhosts",
+			Interval: config.ScheduleInterval("update-binhosts", 6*time.Hour),
+			Run:      binhost.UpdateBinaryPackages,
+		},
+		{
+			Name:     "update-developers",
+			Interval: config.ScheduleInterval("update-developers", 24*time.Hour),
+			Run:      developers.UpdateDevelopers,
+		},
+		{
+			Name:     "export",
+			Interval: config.ScheduleInterval("export", 24*time.Hour),
+			After:    []string{"update", "update-bugs", "update-dependencies"},
+			Run:      dumps.Export,
+		},
+		{
+			Name:        "update-maintainers",
+			Interval:    config.ScheduleInterval("update-maintainers", time.Hour),
+			After:       []string{"update"},
+			TriggeredBy: []string{"update-bugs", "update-pullrequests", "update-outdated-packages", "update-pkgcheck-results", "update-projects"},
+			Run:         maintainers.FullImport,
+		},
+	}
+}
+
+// runProcess returns a job running soko with the given arguments in
+// a new process, which fails in case the process exits with an error
+func runProcess(args ...string) func() error {
+	return func() error {
+		cmd := exec.Command(os.Args[0], args...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		return cmd.Run()
+	}
 }
 
-func updateOutdated() {
+func updateOutdated() error {
+	return errors.Join(updateOutdatedPackages(), updateOutdatedCategories())
+}
+
+// updateOutdatedPackages updates the outdated packages of both anitya
+// and repology. A failing source doesn't prevent the update of the
+// other one, the errors of both are returned.
+func updateOutdatedPackages() error {
 	database.Connect()
 	defer database.DBCon.Close()
 
 	slog.Info("Updating the anitya data")
-	anitya.UpdateAnitya()
+	anityaErr := anitya.UpdateAnitya()
 	slog.Info("Updating the repology data")
-	repology.UpdateOutdated()
+	return errors.Join(anityaErr, repology.UpdateOutdated())
+}
+
+func updateOutdatedCategories() error {
+	database.Connect()
+	defer database.DBCon.Close()
 
-	repology.UpdateCategoriesMetadata()
+	return repology.UpdateCategoriesMetadata()
 }
 
 // initialize the loggers depending on whether